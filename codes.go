@@ -0,0 +1,178 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrorCode describes a stable application error code, such as
+// "BILLING_CARD_DECLINED", which is independent of the transport used to
+// report the error. Error codes are registered by packages at init time
+// with RegisterCode or MustRegisterCode.
+type ErrorCode struct {
+	// Code holds the application error code. It must be unique among
+	// all registered codes.
+	Code string
+
+	// Status holds the HTTP response code to be sent for errors with
+	// this code.
+	Status int
+
+	// GRPCCode holds the gRPC status code for errors with this code, as
+	// defined by google.golang.org/grpc/codes.
+	GRPCCode uint32
+
+	// Message holds the default message of errors with this code.
+	Message string
+}
+
+// String returns the application error code.
+func (c *ErrorCode) String() string {
+	return c.Code
+}
+
+var registry = struct {
+	sync.RWMutex
+	codes map[string]*ErrorCode
+}{
+	codes: make(map[string]*ErrorCode),
+}
+
+// RegisterCode registers the given error code, returning the registered
+// code for use with the Codef and NewCoded constructors. It returns an
+// error if the code is empty or has already been registered.
+func RegisterCode(code ErrorCode) (*ErrorCode, error) {
+	if code.Code == "" {
+		return nil, New("empty error code")
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.codes[code.Code]; ok {
+		return nil, Errorf("error code %q already registered", code.Code)
+	}
+	c := &code
+	registry.codes[c.Code] = c
+	return c, nil
+}
+
+// MustRegisterCode is like RegisterCode but panics if the code cannot be
+// registered. It is intended for use in package level variables.
+//
+// For example:
+//     var ErrCardDeclined = errgo.MustRegisterCode(errgo.ErrorCode{
+//         Code:     "BILLING_CARD_DECLINED",
+//         Status:   http.StatusPaymentRequired,
+//         GRPCCode: uint32(codes.FailedPrecondition),
+//         Message:  "the card was declined",
+//     })
+func MustRegisterCode(code ErrorCode) *ErrorCode {
+	c, err := RegisterCode(code)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// LookupCode returns the registered error code with the given code, if
+// any.
+func LookupCode(code string) (*ErrorCode, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	c, ok := registry.codes[code]
+	return c, ok
+}
+
+// RegisteredCodes returns all registered error codes, sorted by code.
+func RegisteredCodes() []*ErrorCode {
+	registry.RLock()
+	defer registry.RUnlock()
+	codes := make([]*ErrorCode, 0, len(registry.codes))
+	for _, c := range registry.codes {
+		codes = append(codes, c)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		return codes[i].Code < codes[j].Code
+	})
+	return codes
+}
+
+// Codef returns an error with the given application error code. Its HTTP
+// response code is the Status of the error code, and its message is
+// formatted from format and args, or is the default Message of the error
+// code if format is empty. If code is nil, the error has no codes.
+func Codef(code *ErrorCode, format string, args ...interface{}) error {
+	e := newCoded(nil, code, format, args...)
+	return &e
+}
+
+// NewCoded returns an error which wraps err and has the given application
+// error code, as for Codef. If code is nil, the error has no codes.
+func NewCoded(err error, code *ErrorCode, msg string) error {
+	e := newCoded(err, code, msg)
+	return &e
+}
+
+// newCoded is a helper to construct the errors returned by Codef and
+// NewCoded.
+func newCoded(err error, code *ErrorCode, format string, args ...interface{}) Err {
+	var message, safeMessage string
+	if code != nil {
+		message = code.Message
+	}
+	if format != "" {
+		message = fmt.Sprintf(format, args...)
+		safeMessage = redactf(format, args)
+	}
+	newErr := Err{
+		message:     message,
+		safeMessage: safeMessage,
+		format:      format,
+		previous:    err,
+		errorCode:   code,
+	}
+	if code != nil {
+		newErr.code = code.Status
+	}
+	newErr.SetLocation(2)
+	newErr.captureStack(2)
+	return newErr
+}
+
+// ErrorCode returns the application error code of the error, or nil.
+func (e *Err) ErrorCode() *ErrorCode {
+	return e.errorCode
+}
+
+type errorCoder interface {
+	ErrorCode() *ErrorCode
+}
+
+// ErrorCodeOf returns the application error code of err, or nil if it has
+// none. The stack of err is searched in the same way as for Code.
+func ErrorCodeOf(err error) *ErrorCode {
+	if e, ok := Cause(err).(errorCoder); ok && e.ErrorCode() != nil {
+		return e.ErrorCode()
+	}
+	found := findError(err, func(err error) bool {
+		e, ok := err.(errorCoder)
+		return ok && e.ErrorCode() != nil
+	})
+	if found == nil {
+		return nil
+	}
+	return found.(errorCoder).ErrorCode()
+}
+
+// HasCode reports whether err has the given application error code, as
+// returned by ErrorCodeOf. It returns false if code is nil.
+func HasCode(err error, code *ErrorCode) bool {
+	if code == nil {
+		return false
+	}
+	c := ErrorCodeOf(err)
+	return c != nil && c.Code == code.Code
+}
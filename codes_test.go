@@ -0,0 +1,163 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/hifx/errgo"
+)
+
+type codesSuite struct{}
+
+var _ = gc.Suite(&codesSuite{})
+
+var (
+	errCardDeclined = errgo.MustRegisterCode(errgo.ErrorCode{
+		Code:     "TEST_CARD_DECLINED",
+		Status:   http.StatusPaymentRequired,
+		GRPCCode: 9,
+		Message:  "the card was declined",
+	})
+	errAccountLocked = errgo.MustRegisterCode(errgo.ErrorCode{
+		Code:    "TEST_ACCOUNT_LOCKED",
+		Status:  http.StatusLocked,
+		Message: "the account is locked",
+	})
+)
+
+func (*codesSuite) TestRegisterCode(c *gc.C) {
+	code, err := errgo.RegisterCode(errgo.ErrorCode{
+		Code:    "TEST_REGISTER",
+		Status:  http.StatusTeapot,
+		Message: "short and stout",
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(code.String(), gc.Equals, "TEST_REGISTER")
+
+	found, ok := errgo.LookupCode("TEST_REGISTER")
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(found, gc.Equals, code)
+
+	_, err = errgo.RegisterCode(errgo.ErrorCode{Code: "TEST_REGISTER"})
+	c.Assert(err, gc.ErrorMatches, `error code "TEST_REGISTER" already registered`)
+	found, _ = errgo.LookupCode("TEST_REGISTER")
+	c.Assert(found, gc.Equals, code)
+
+	_, err = errgo.RegisterCode(errgo.ErrorCode{})
+	c.Assert(err, gc.ErrorMatches, "empty error code")
+
+	c.Assert(func() {
+		errgo.MustRegisterCode(errgo.ErrorCode{Code: "TEST_CARD_DECLINED"})
+	}, gc.PanicMatches, `error code "TEST_CARD_DECLINED" already registered`)
+}
+
+func (*codesSuite) TestLookupCode(c *gc.C) {
+	code, ok := errgo.LookupCode("TEST_CARD_DECLINED")
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(code, gc.Equals, errCardDeclined)
+	c.Assert(code.GRPCCode, gc.Equals, uint32(9))
+
+	code, ok = errgo.LookupCode("TEST_UNKNOWN")
+	c.Assert(ok, jc.IsFalse)
+	c.Assert(code, gc.IsNil)
+}
+
+func (*codesSuite) TestRegisteredCodes(c *gc.C) {
+	var names []string
+	for _, code := range errgo.RegisteredCodes() {
+		names = append(names, code.Code)
+	}
+	c.Assert(sort.StringsAreSorted(names), jc.IsTrue)
+	c.Assert(strings.Join(names, " "), jc.Contains, "TEST_ACCOUNT_LOCKED TEST_CARD_DECLINED")
+}
+
+func (*codesSuite) TestCodef(c *gc.C) {
+	err := errgo.Codef(errCardDeclined, "card %s declined", "1234")
+	c.Assert(err.Error(), gc.Equals, "card 1234 declined")
+	c.Assert(errgo.ErrorCodeOf(err), gc.Equals, errCardDeclined)
+	c.Assert(errgo.Code(err), gc.Equals, http.StatusPaymentRequired)
+	c.Assert(errgo.IsPaymentRequired(err), jc.IsTrue)
+
+	err = errgo.Codef(errCardDeclined, "")
+	c.Assert(err.Error(), gc.Equals, "the card was declined")
+}
+
+func (*codesSuite) TestNewCoded(c *gc.C) {
+	err := errgo.NewCoded(fmt.Errorf("gateway said no"), errAccountLocked, "")
+	c.Assert(err.Error(), gc.Equals, "the account is locked: gateway said no")
+	c.Assert(errgo.ErrorCodeOf(err), gc.Equals, errAccountLocked)
+	c.Assert(errgo.IsLocked(err), jc.IsTrue)
+}
+
+func (*codesSuite) TestNilCode(c *gc.C) {
+	err := errgo.Codef(nil, "card %s declined", "1234")
+	c.Assert(err.Error(), gc.Equals, "card 1234 declined")
+	c.Assert(errgo.ErrorCodeOf(err), gc.IsNil)
+	c.Assert(errgo.Code(err), gc.Equals, 0)
+
+	err = errgo.NewCoded(fmt.Errorf("gateway said no"), nil, "declined")
+	c.Assert(err.Error(), gc.Equals, "declined: gateway said no")
+	c.Assert(errgo.ErrorCodeOf(err), gc.IsNil)
+
+	c.Assert(errgo.HasCode(err, nil), jc.IsFalse)
+	c.Assert(errgo.HasCode(errgo.Codef(errCardDeclined, ""), nil), jc.IsFalse)
+}
+
+func (*codesSuite) TestErrorCodeOf(c *gc.C) {
+	for i, test := range []struct {
+		message string
+		err     error
+		code    *errgo.ErrorCode
+	}{{
+		message: "nil",
+		err:     nil,
+	}, {
+		message: "no code",
+		err:     errgo.NotFoundf("user"),
+	}, {
+		message: "annotated",
+		err:     errgo.Annotate(errgo.Codef(errCardDeclined, ""), "paying"),
+		code:    errCardDeclined,
+	}, {
+		message: "masked",
+		err:     errgo.Mask(errgo.Codef(errCardDeclined, "")),
+		code:    errCardDeclined,
+	}, {
+		message: "most recent code wins",
+		err:     errgo.NewCoded(errgo.Codef(errCardDeclined, ""), errAccountLocked, ""),
+		code:    errAccountLocked,
+	}, {
+		message: "cause wins",
+		err:     errgo.Wrap(errgo.Codef(errCardDeclined, ""), errgo.Codef(errAccountLocked, "")),
+		code:    errAccountLocked,
+	}, {
+		message: "wrapped by fmt",
+		err:     fmt.Errorf("paying: %w", errgo.Codef(errCardDeclined, "")),
+		code:    errCardDeclined,
+	}} {
+		c.Logf("%v: %s", i, test.message)
+		c.Check(errgo.ErrorCodeOf(test.err), gc.Equals, test.code)
+		c.Check(errgo.HasCode(test.err, errCardDeclined), gc.Equals, test.code == errCardDeclined)
+	}
+}
+
+func (*codesSuite) TestJSON(c *gc.C) {
+	data, err := json.Marshal(errgo.Annotate(errgo.Codef(errCardDeclined, ""), "paying"))
+	c.Assert(err, gc.IsNil)
+	var obtained errgo.Err
+	c.Assert(json.Unmarshal(data, &obtained), gc.IsNil)
+	c.Assert(errgo.ErrorCodeOf(&obtained), gc.Equals, errCardDeclined)
+
+	err = json.Unmarshal([]byte(`{"message":"x","errorCode":"TEST_UNKNOWN"}`), &obtained)
+	c.Assert(err, gc.IsNil)
+	c.Assert(obtained.ErrorCode(), gc.IsNil)
+}
@@ -0,0 +1,210 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// The names of the context values commonly used to correlate errors with
+// requests and traces. Values must be registered under these names with
+// RegisterContextValue or RegisterContextKey before they are recorded.
+const (
+	CtxRequestID = "requestId"
+	CtxTraceID   = "traceId"
+	CtxSpanID    = "spanId"
+	CtxTenant    = "tenant"
+)
+
+// contextValue holds a registered context value.
+type contextValue struct {
+	name  string
+	value func(ctx context.Context) string
+}
+
+var contextRegistry struct {
+	sync.RWMutex
+	values []contextValue
+}
+
+// RegisterContextValue registers a context value to be recorded by
+// NewCtx, AnnotateCtx and TraceCtx under the given name. The value
+// function returns the value held by a context, or the empty string if
+// there is none. Registering a value under the same name again replaces
+// it.
+//
+// For example:
+//     errgo.RegisterContextValue(errgo.CtxTraceID, func(ctx context.Context) string {
+//         if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+//             return sc.TraceID().String()
+//         }
+//         return ""
+//     })
+func RegisterContextValue(name string, value func(ctx context.Context) string) {
+	contextRegistry.Lock()
+	defer contextRegistry.Unlock()
+	for i, v := range contextRegistry.values {
+		if v.name == name {
+			contextRegistry.values[i].value = value
+			return
+		}
+	}
+	contextRegistry.values = append(contextRegistry.values, contextValue{name, value})
+}
+
+// RegisterContextKey registers the value stored in a context under key,
+// as by context.WithValue, to be recorded under the given name as for
+// RegisterContextValue. The value is formatted with fmt.Sprint.
+func RegisterContextKey(name string, key interface{}) {
+	RegisterContextValue(name, func(ctx context.Context) string {
+		if v := ctx.Value(key); v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	})
+}
+
+// valuesFromContext returns the registered values held by ctx, or nil if
+// there are none.
+func valuesFromContext(ctx context.Context) map[string]string {
+	if ctx == nil {
+		return nil
+	}
+	contextRegistry.RLock()
+	defer contextRegistry.RUnlock()
+	var values map[string]string
+	for _, v := range contextRegistry.values {
+		value := v.value(ctx)
+		if value == "" {
+			continue
+		}
+		if values == nil {
+			values = make(map[string]string)
+		}
+		values[v.name] = value
+	}
+	return values
+}
+
+// NewCtx is like New, but also records the registered values held by ctx,
+// such as the request ID.
+func NewCtx(ctx context.Context, message string) error {
+	err := &Err{
+		message:       message,
+		contextValues: valuesFromContext(ctx),
+	}
+	err.SetLocation(1)
+	err.captureStack(1)
+	return err
+}
+
+// AnnotateCtx is like Annotate, but also records the registered values
+// held by ctx, such as the request ID.
+//
+// For example:
+//   if err := SomeFunc(ctx); err != nil {
+//       return errgo.AnnotateCtx(ctx, err, "failed to frombulate")
+//   }
+//
+func AnnotateCtx(ctx context.Context, other error, message string) error {
+	if other == nil {
+		return nil
+	}
+	err := &Err{
+		previous:      other,
+		cause:         Cause(other),
+		message:       message,
+		contextValues: valuesFromContext(ctx),
+	}
+	err.SetLocation(1)
+	return err
+}
+
+// TraceCtx is like Trace, but also records the registered values held by
+// ctx, such as the request ID.
+func TraceCtx(ctx context.Context, other error) error {
+	if other == nil {
+		return nil
+	}
+	err := &Err{
+		previous:      other,
+		cause:         Cause(other),
+		contextValues: valuesFromContext(ctx),
+	}
+	err.SetLocation(1)
+	return err
+}
+
+// ContextValues returns the context values recorded for this entry in the
+// error stack.
+func (e *Err) ContextValues() map[string]string {
+	return e.contextValues
+}
+
+type contextValuer interface {
+	ContextValues() map[string]string
+}
+
+var _ contextValuer = (*Err)(nil)
+
+// ContextValues returns the context values recorded for all the errors in
+// the stack of err, searched as for Code, merged into one map. Where a
+// value was recorded more than once the most recent one is used.
+func ContextValues(err error) map[string]string {
+	var result map[string]string
+	visitErrors(err, func(e error) {
+		e1, ok := e.(contextValuer)
+		if !ok {
+			return
+		}
+		for name, value := range e1.ContextValues() {
+			if result == nil {
+				result = make(map[string]string)
+			}
+			if _, ok := result[name]; !ok {
+				result[name] = value
+			}
+		}
+	})
+	return result
+}
+
+// ContextValue returns the most recent context value recorded under name
+// in the error stack of err, or the empty string if there is none.
+func ContextValue(err error, name string) string {
+	return ContextValues(err)[name]
+}
+
+// RequestID returns the request ID recorded in the error stack of err.
+func RequestID(err error) string {
+	return ContextValue(err, CtxRequestID)
+}
+
+// TraceID returns the trace ID recorded in the error stack of err.
+func TraceID(err error) string {
+	return ContextValue(err, CtxTraceID)
+}
+
+// SpanID returns the span ID recorded in the error stack of err.
+func SpanID(err error) string {
+	return ContextValue(err, CtxSpanID)
+}
+
+// Tenant returns the tenant recorded in the error stack of err.
+func Tenant(err error) string {
+	return ContextValue(err, CtxTenant)
+}
+
+// sortedNames returns the names of values in order.
+func sortedNames(values map[string]string) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,163 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/hifx/errgo"
+)
+
+type contextSuite struct {
+	restore func()
+}
+
+var _ = gc.Suite(&contextSuite{})
+
+type contextKey string
+
+func (s *contextSuite) SetUpTest(c *gc.C) {
+	s.restore = errgo.ResetContextValues()
+	errgo.RegisterContextKey(errgo.CtxRequestID, contextKey("request"))
+	errgo.RegisterContextKey(errgo.CtxTenant, contextKey("tenant"))
+	errgo.RegisterContextValue(errgo.CtxTraceID, func(ctx context.Context) string {
+		if trace, ok := ctx.Value(contextKey("trace")).([2]string); ok {
+			return trace[0]
+		}
+		return ""
+	})
+	errgo.RegisterContextValue(errgo.CtxSpanID, func(ctx context.Context) string {
+		if trace, ok := ctx.Value(contextKey("trace")).([2]string); ok {
+			return trace[1]
+		}
+		return ""
+	})
+}
+
+func (s *contextSuite) TearDownTest(c *gc.C) {
+	s.restore()
+}
+
+func requestContext() context.Context {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, contextKey("request"), "req-1")
+	ctx = context.WithValue(ctx, contextKey("tenant"), "acme")
+	ctx = context.WithValue(ctx, contextKey("trace"), [2]string{"4bf92f3577b34da6", "00f067aa0ba902b7"})
+	return ctx
+}
+
+func (*contextSuite) TestNewCtx(c *gc.C) {
+	err := errgo.NewCtx(requestContext(), "first")
+	c.Assert(err.Error(), gc.Equals, "first")
+	c.Assert(err.(*errgo.Err).ContextValues(), jc.DeepEquals, map[string]string{
+		"requestId": "req-1",
+		"tenant":    "acme",
+		"traceId":   "4bf92f3577b34da6",
+		"spanId":    "00f067aa0ba902b7",
+	})
+	c.Assert(errgo.RequestID(err), gc.Equals, "req-1")
+	c.Assert(errgo.TraceID(err), gc.Equals, "4bf92f3577b34da6")
+	c.Assert(errgo.SpanID(err), gc.Equals, "00f067aa0ba902b7")
+	c.Assert(errgo.Tenant(err), gc.Equals, "acme")
+}
+
+func (*contextSuite) TestAnnotateCtx(c *gc.C) {
+	first := errgo.New("first")
+	err := errgo.AnnotateCtx(requestContext(), first, "annotation")
+	c.Assert(err.Error(), gc.Equals, "annotation: first")
+	c.Assert(errgo.Cause(err), gc.Equals, first)
+	c.Assert(errgo.RequestID(err), gc.Equals, "req-1")
+
+	c.Assert(errgo.AnnotateCtx(requestContext(), nil, "annotation"), gc.IsNil)
+}
+
+func (*contextSuite) TestTraceCtx(c *gc.C) {
+	first := errgo.New("first")
+	err := errgo.TraceCtx(requestContext(), first)
+	c.Assert(err.Error(), gc.Equals, "first")
+	c.Assert(errgo.Cause(err), gc.Equals, first)
+	c.Assert(errgo.Tenant(err), gc.Equals, "acme")
+
+	c.Assert(errgo.TraceCtx(requestContext(), nil), gc.IsNil)
+}
+
+func (*contextSuite) TestContextValues(c *gc.C) {
+	c.Assert(errgo.ContextValues(errgo.New("first")), gc.IsNil)
+	c.Assert(errgo.RequestID(nil), gc.Equals, "")
+
+	// Values missing from the context are not recorded.
+	ctx := context.WithValue(context.Background(), contextKey("request"), "req-1")
+	err := errgo.NewCtx(ctx, "first")
+	c.Assert(err.(*errgo.Err).ContextValues(), jc.DeepEquals, map[string]string{"requestId": "req-1"})
+	c.Assert(errgo.NewCtx(context.Background(), "first").(*errgo.Err).ContextValues(), gc.IsNil)
+
+	// The most recent value wins.
+	ctx = context.WithValue(context.Background(), contextKey("request"), "req-2")
+	ctx = context.WithValue(ctx, contextKey("tenant"), "acme")
+	err = errgo.Annotate(err, "annotation")
+	err = errgo.TraceCtx(ctx, err)
+	c.Assert(errgo.ContextValues(err), jc.DeepEquals, map[string]string{
+		"requestId": "req-2",
+		"tenant":    "acme",
+	})
+	c.Assert(errgo.ContextValue(err, errgo.CtxTenant), gc.Equals, "acme")
+
+	// Values are found through other errors.
+	err = fmt.Errorf("wrapped: %w", errgo.NewCtx(requestContext(), "first"))
+	c.Assert(errgo.RequestID(err), gc.Equals, "req-1")
+	err = errgo.Join(io.EOF, errgo.TraceCtx(requestContext(), io.EOF))
+	c.Assert(errgo.Tenant(err), gc.Equals, "acme")
+}
+
+func (*contextSuite) TestJSON(c *gc.C) {
+	err := errgo.AnnotateCtx(requestContext(), errgo.New("first"), "annotation")
+	data, jsonErr := json.Marshal(err)
+	c.Assert(jsonErr, gc.IsNil)
+	c.Assert(string(data), jc.Contains, `"context":{"requestId":"req-1","spanId":"00f067aa0ba902b7","tenant":"acme","traceId":"4bf92f3577b34da6"}`)
+
+	var obtained errgo.Err
+	c.Assert(json.Unmarshal(data, &obtained), gc.IsNil)
+	c.Assert(errgo.RequestID(&obtained), gc.Equals, "req-1")
+}
+
+func (*contextSuite) TestLogValue(c *gc.C) {
+	err := errgo.TraceCtx(requestContext(), errgo.New("first"))
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Error("failed", "err", err)
+	var record map[string]interface{}
+	c.Assert(json.Unmarshal(buf.Bytes(), &record), gc.IsNil)
+	c.Assert(record["err"].(map[string]interface{})["context"], jc.DeepEquals, map[string]interface{}{
+		"requestId": "req-1",
+		"spanId":    "00f067aa0ba902b7",
+		"tenant":    "acme",
+		"traceId":   "4bf92f3577b34da6",
+	})
+}
+
+func (*contextSuite) TestNewProblem(c *gc.C) {
+	err := errgo.AnnotateCtx(requestContext(), errgo.NotFoundf("user"), "loading")
+	p := errgo.NewProblem(err)
+	c.Assert(p.Extensions, jc.DeepEquals, map[string]interface{}{
+		"requestId": "req-1",
+		"spanId":    "00f067aa0ba902b7",
+		"tenant":    "acme",
+		"traceId":   "4bf92f3577b34da6",
+	})
+
+	// Extension members of the errors take precedence.
+	err = errgo.AnnotateCtx(requestContext(), newExtendedError(map[string]interface{}{
+		"tenant": "other",
+	}), "buying")
+	p = errgo.NewProblem(err)
+	c.Assert(p.Extensions["tenant"], gc.Equals, "other")
+	c.Assert(p.Extensions["requestId"], gc.Equals, "req-1")
+}
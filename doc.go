@@ -77,4 +77,13 @@ for the situation.
 This returns an error where the complete error stack is still available, and
 `errgo.Cause()` will return the `NotFound` error.
 
+The errors are also understood by the standard library `errors.Is` and
+`errors.As` functions. These look through Trace and Annotate calls to the
+original error, through a Wrap call to the new cause only, and not at all
+through a Mask call.
+
+	err := errgo.Annotate(io.EOF, "context")
+	errors.Is(err, io.EOF) -> true
+	errors.Is(errgo.Mask(err), io.EOF) -> false
+
 */
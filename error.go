@@ -8,6 +8,7 @@ import (
 	"reflect"
 	"runtime"
 	"strings"
+	"time"
 )
 
 // Err holds a description of an error along with information about
@@ -20,6 +21,31 @@ type Err struct {
 	// message holds an annotation of the error.
 	message string
 
+	// safeMessage holds the message with any sensitive arguments
+	// redacted, or is empty if there were none.
+	safeMessage string
+
+	// format holds the format string the message was created from, if
+	// any.
+	format string
+
+	// publicMessage holds the message to be shown to clients in place
+	// of the internal messages, if any.
+	publicMessage string
+
+	// templateArgs holds the arguments for the templates of the
+	// translations of the message, if any.
+	templateArgs map[string]interface{}
+
+	// contextValues holds the registered values of the context the
+	// error was created with, if any.
+	contextValues map[string]string
+
+	// retry holds the retry classification of the error, and retryAfter
+	// the delay before a retry, if any.
+	retry      retryClass
+	retryAfter time.Duration
+
 	// cause holds the cause of the error as returned
 	// by the Cause method.
 	cause error
@@ -27,6 +53,10 @@ type Err struct {
 	// previous holds the previous error in the error stack, if any.
 	previous error
 
+	// masked records that the error was created by Mask or Maskf, and
+	// so hides the previous error from Unwrap.
+	masked bool
+
 	// file, line and function hold the source code location where the error was
 	// created.
 	file     string
@@ -36,31 +66,70 @@ type Err struct {
 	// the http response code to be sent for the error.
 	code int
 
+	// errorCode holds the application error code of the error, if any.
+	errorCode *ErrorCode
+
+	// fields holds the key/value pairs attached to this entry in the
+	// error stack.
+	fields []Field
+
 	// http content type of the error
 	contentType string
 
 	// the stack trace for the error
 	stack string
+
+	// pcs holds the program counters of the call stack when the error was
+	// created, if stack capture was enabled.
+	pcs []uintptr
 }
 
 // NewErr is used to return an Err for the purpose of embedding in other
 // structures.  The location is not specified, and needs to be set with a call
 // to SetLocation.
 //
+// The Format, LogValue, SafeError and MarshalJSON methods of *Err are
+// promoted to the embedding type, but only see the embedded Err, so a type
+// which defines its own Error method or has fields of its own must define
+// them as well. Otherwise its values are printed, logged and encoded as
+// the embedded Err alone.
+//
 // For example:
 //     type FooError struct {
 //         errgo.Err
-//         code int
+//         ID int `json:"id"`
 //     }
 //
-//     func NewFooError(code int) error {
-//         err := &FooError{errgo.NewErr("foo"), code}
+//     func NewFooError(id int) error {
+//         err := &FooError{errgo.NewErr(http.StatusNotFound, "foo"), id}
 //         err.SetLocation(1)
 //         return err
 //     }
+//
+//     func (e *FooError) Error() string {
+//         return fmt.Sprintf("foo %d: %s", e.ID, e.Err.Error())
+//     }
+//
+//     func (e *FooError) SafeError() string {
+//         return fmt.Sprintf("foo %d: %s", e.ID, e.Err.SafeError())
+//     }
+//
+//     func (e *FooError) Format(s fmt.State, verb rune) {
+//         errgo.FormatError(s, verb, e)
+//     }
+//
+//     func (e *FooError) LogValue() slog.Value {
+//         return errgo.LogValue(e)
+//     }
+//
+//     func (e *FooError) MarshalJSON() ([]byte, error) {
+//         return json.Marshal(map[string]interface{}{"error": &e.Err, "id": e.ID})
+//     }
 func NewErr(code int, format string, args ...interface{}) Err {
 	err := Err{
 		message:     fmt.Sprintf(format, args...),
+		safeMessage: redactf(format, args),
+		format:      format,
 		code:        code,
 		contentType: "text/plain; charset=utf-8",
 	}
@@ -76,22 +145,45 @@ func (e Err) Stack() string {
 
 // NewErrWithCause is used to return an Err with case by other error for the purpose of embedding in other
 // structures. The location is not specified, and needs to be set with a call
-// to SetLocation.
+// to SetLocation. As for NewErr, the embedding type must define its own
+// Format, LogValue, SafeError and MarshalJSON methods.
 //
 // For example:
 //     type FooError struct {
 //         errgo.Err
-//         code int
+//         ID int `json:"id"`
 //     }
 //
 //     func (e *FooError) Annotate(format string, args ...interface{}) error {
-//         err := &FooError{errgo.NewErrWithCause(e.Err, format, args...), e.code}
+//         err := &FooError{errgo.NewErrWithCause(e, e.Code(), format, args...), e.ID}
 //         err.SetLocation(1)
 //         return err
-//     })
+//     }
+//
+//     func (e *FooError) Error() string {
+//         return fmt.Sprintf("foo %d: %s", e.ID, e.Err.Error())
+//     }
+//
+//     func (e *FooError) SafeError() string {
+//         return fmt.Sprintf("foo %d: %s", e.ID, e.Err.SafeError())
+//     }
+//
+//     func (e *FooError) Format(s fmt.State, verb rune) {
+//         errgo.FormatError(s, verb, e)
+//     }
+//
+//     func (e *FooError) LogValue() slog.Value {
+//         return errgo.LogValue(e)
+//     }
+//
+//     func (e *FooError) MarshalJSON() ([]byte, error) {
+//         return json.Marshal(map[string]interface{}{"error": &e.Err, "id": e.ID})
+//     }
 func NewErrWithCause(other error, code int, format string, args ...interface{}) Err {
 	err := Err{
 		message:     fmt.Sprintf(format, args...),
+		safeMessage: redactf(format, args),
+		format:      format,
 		cause:       Cause(other),
 		previous:    other,
 		code:        code,
@@ -146,6 +238,21 @@ func (e *Err) Underlying() error {
 	return e.previous
 }
 
+// Unwrap returns the error that the standard library errors.Is and
+// errors.As should inspect next. For an error created by Wrap or Wrapf
+// this is the new cause, as the cause replaces the previous error. For an
+// error created by Mask or Maskf it is nil, as the previous error is
+// hidden. Otherwise it is the previous error in the error stack.
+func (e *Err) Unwrap() error {
+	if e.masked {
+		return nil
+	}
+	if e.cause != nil && !sameError(Cause(e.previous), e.cause) {
+		return e.cause
+	}
+	return e.previous
+}
+
 // Cause of an error is the most recent error in the error stack that
 // meets one of these criteria: the original error that was raised; the new
 // error that was passed into the Wrap function; the most recently masked
@@ -185,7 +292,7 @@ func (e *Err) Error() string {
 func (e *Err) SetLocation(callDepth int) {
 	pc, file, line, _ := runtime.Caller(callDepth + 1)
 	e.function = runtime.FuncForPC(pc).Name()
-	e.file = trimGoPath(file)
+	e.file = trimSourcePath(file, e.function)
 	e.line = line
 }
 
@@ -4,7 +4,11 @@
 package errgo_test
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"runtime"
 
 	jc "github.com/juju/testing/checkers"
@@ -147,6 +151,64 @@ func (*errorsSuite) TestNewErrWithCause(c *gc.C) {
 
 var _ error = (*embed)(nil)
 
+// fooError embeds errgo.Err with its own Error method and fields, defining
+// the methods shown in the NewErr example.
+type fooError struct {
+	errgo.Err
+	ID int `json:"id"`
+}
+
+func (e *fooError) Error() string {
+	return fmt.Sprintf("foo %d: %s", e.ID, e.Err.Error())
+}
+
+func (e *fooError) SafeError() string {
+	return fmt.Sprintf("foo %d: %s", e.ID, e.Err.SafeError())
+}
+
+func (e *fooError) Format(s fmt.State, verb rune) {
+	errgo.FormatError(s, verb, e)
+}
+
+func (e *fooError) LogValue() slog.Value {
+	return errgo.LogValue(e)
+}
+
+func (e *fooError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{"error": &e.Err, "id": e.ID})
+}
+
+func (*errorsSuite) TestNewErrEmbeddedOverridingError(c *gc.C) {
+	err := &fooError{errgo.NewErr(http.StatusNotFound, "no user %s", errgo.Sensitive("jo")), 42}
+	c.Assert(fmt.Sprintf("%v", err), gc.Equals, "foo 42: no user jo")
+	c.Assert(fmt.Sprintf("%s", err), gc.Equals, "foo 42: no user jo")
+	c.Assert(errgo.SafeError(err), gc.Equals, "foo 42: no user [REDACTED]")
+
+	data, jsonErr := json.Marshal(err)
+	c.Assert(jsonErr, gc.IsNil)
+	var encoded struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+		ID int `json:"id"`
+	}
+	c.Assert(json.Unmarshal(data, &encoded), gc.IsNil)
+	c.Assert(encoded.ID, gc.Equals, 42)
+	c.Assert(encoded.Error.Message, gc.Equals, "no user jo")
+
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Error("failed", "err", err)
+	var record struct {
+		Err struct {
+			Message string `json:"message"`
+			Code    int    `json:"code"`
+		} `json:"err"`
+	}
+	c.Assert(json.Unmarshal(buf.Bytes(), &record), gc.IsNil)
+	c.Assert(record.Err.Message, gc.Equals, "foo 42: no user [REDACTED]")
+	c.Assert(record.Err.Code, gc.Equals, http.StatusNotFound)
+}
+
 // This is an uncomparable error type, as it is a struct that supports the
 // error interface (as opposed to a pointer type).
 type error_ struct {
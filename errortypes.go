@@ -5,174 +5,76 @@ package errgo
 
 import (
 	"fmt"
-	"net/http"
 )
 
+// The Xf, NewX and IsX functions for each HTTP error kind are generated
+// from the table in gen_errortypes.go, so that the family stays uniform.
+//go:generate go run gen_errortypes.go
+
 // wrap is a helper to construct an *wrapper.
 func wrap(err error, code int, format, suffix string, args ...interface{}) Err {
 	newErr := Err{
-		message:  fmt.Sprintf(format+suffix, args...),
-		previous: err,
+		message:     fmt.Sprintf(format+suffix, args...),
+		safeMessage: redactf(format+suffix, args),
+		format:      format + suffix,
+		previous:    err,
 	}
 	newErr.SetLocation(2)
+	newErr.captureStack(2)
 	newErr.SetCode(code)
 	return newErr
 }
 
-// InternalServer represents an error when something unexpected has happened.
-
-// InternalServerf returns an error which satisfies IsInternalServer().
-func InternalServerf(format string, args ...interface{}) error {
-	e := wrap(nil, http.StatusInternalServerError, format, "", args...)
-	return &e
-}
-
-// NewInternalServer returns an error which wraps err that satisfies
-// IsInternalServer().
-func NewInternalServer(err error, msg string) error {
-	e := wrap(err, http.StatusInternalServerError, msg, "")
-	return &e
-}
-
-// IsInternalServer reports whether err was created with InternalServerf() or
-// NewInternalServer().
-func IsInternalServer(err error) bool {
-	err = Cause(err)
-	e, ok := err.(*Err)
-	if ok {
-		return e.Code() == http.StatusInternalServerError
-	}
-	return ok
-}
-
-// NotFound represents an error when something has not been found.
-
-// NotFoundf returns an error which satisfies IsNotFound().
-func NotFoundf(format string, args ...interface{}) error {
-	e := wrap(nil, http.StatusNotFound, format, "", args...)
-	return &e
-}
-
-// NewNotFound returns an error which wraps err that satisfies
-// IsNotFound().
-func NewNotFound(err error, msg string) error {
-	e := wrap(err, http.StatusNotFound, msg, "")
-	return &e
-}
-
-// IsNotFound reports whether err was created with NotFoundf() or
-// NewNotFound().
-func IsNotFound(err error) bool {
-	err = Cause(err)
-	e, ok := err.(*Err)
-	if ok {
-		return e.Code() == http.StatusNotFound
-	}
-	return ok
-}
-
-// Unauthorized represents an error when an operation is unauthorized.
-
-// Unauthorizedf returns an error which satisfies IsUnauthorized().
-func Unauthorizedf(format string, args ...interface{}) error {
-	e := wrap(nil, http.StatusUnauthorized, format, "", args...)
-	return &e
-}
-
-// NewUnauthorized returns an error which wraps err and satisfies
-// IsUnauthorized().
-func NewUnauthorized(err error, msg string) error {
-	e := wrap(err, http.StatusUnauthorized, msg, "")
-	return &e
-}
-
-// IsUnauthorized reports whether err was created with Unauthorizedf() or
-// NewUnauthorized().
-func IsUnauthorized(err error) bool {
-	err = Cause(err)
-	e, ok := err.(*Err)
-	if ok {
-		return e.Code() == http.StatusUnauthorized
+// Code returns the HTTP response code of err, or 0 if it has none. Any
+// error with a Code() int method carries a code, so errors embedding Err
+// are recognized as well as *Err itself. When several errors in the stack
+// carry a code, the code of the Cause of err takes precedence. Otherwise
+// the stack is searched from the most recent error back to the original
+// one, through Underlying or the standard Unwrap methods, and the first
+// code found is used. An error passed to Wrap is searched before the
+// error it replaces, and Mask does not hide the codes beneath it.
+//
+// For example, the code of
+//     errgo.Annotate(errgo.NewBadRequest(errgo.NotFoundf("user"), "bad user"), "login")
+// is http.StatusBadRequest, while the code of
+//     errgo.Wrap(errgo.BadRequestf("bad user"), errgo.NotFoundf("user"))
+// is http.StatusNotFound.
+func Code(err error) int {
+	if err := coderOf(err); err != nil {
+		return err.Code()
 	}
-	return ok
+	return 0
 }
 
-// NotImplemented represents an error when something is not
-// implemented.
-
-// NotImplementedf returns an error which satisfies IsNotImplemented().
-func NotImplementedf(format string, args ...interface{}) error {
-	e := wrap(nil, http.StatusNotImplemented, format, "", args...)
-	return &e
+// hasCode reports whether the HTTP response code of err, as returned by
+// Code, is code.
+func hasCode(err error, code int) bool {
+	return Code(err) == code
 }
 
-// NewNotImplemented returns an error which wraps err and satisfies
-// IsNotImplemented().
-func NewNotImplemented(err error, msg string) error {
-	e := wrap(err, http.StatusNotImplemented, msg, "")
-	return &e
+type coderError interface {
+	error
+	coder
 }
 
-// IsNotImplemented reports whether err was created with
-// NotImplementedf() or NewNotImplemented().
-func IsNotImplemented(err error) bool {
-	err = Cause(err)
-	e, ok := err.(*Err)
-	if ok {
-		return e.Code() == http.StatusNotImplemented
+// coderOf returns the error in the stack of err whose HTTP response code
+// is returned by Code, or nil.
+func coderOf(err error) coderError {
+	if err, ok := Cause(err).(coderError); ok && err.Code() != 0 {
+		return err
 	}
-	return ok
-}
-
-// BadRequest represents an error when a request has bad parameters
-
-// BadRequestf returns an error which satisfies IsBadRequest().
-func BadRequestf(format string, args ...interface{}) error {
-	e := wrap(nil, http.StatusBadRequest, format, "", args...)
-	return &e
-}
-
-// NewBadRequest returns an error which wraps err that satisfies
-// IsBadRequest().
-func NewBadRequest(err error, msg string) error {
-	e := wrap(err, http.StatusBadRequest, msg, "")
-	return &e
-}
-
-// IsBadRequest reports whether err was created with BadRequestf() or
-// NewBadRequest().
-func IsBadRequest(err error) bool {
-	err = Cause(err)
-	e, ok := err.(*Err)
-	if ok {
-		return e.Code() == http.StatusBadRequest
-	}
-	return ok
-}
-
-// MethodNotAllowed represents an error when an HTTP request
-// is made with an inappropriate method.
-
-// MethodNotAllowedf returns an error which satisfies IsMethodNotAllowed().
-func MethodNotAllowedf(format string, args ...interface{}) error {
-	e := wrap(nil, http.StatusMethodNotAllowed, format, "", args...)
-	return &e
-}
-
-// NewMethodNotAllowed returns an error which wraps err that satisfies
-// IsMethodNotAllowed().
-func NewMethodNotAllowed(err error, msg string) error {
-	e := wrap(err, http.StatusMethodNotAllowed, msg, "")
-	return &e
-}
-
-// IsMethodNotAllowed reports whether err was created with MethodNotAllowedf() or
-// NewMethodNotAllowed().
-func IsMethodNotAllowed(err error) bool {
-	err = Cause(err)
-	e, ok := err.(*Err)
-	if ok {
-		return e.Code() == http.StatusMethodNotAllowed
+	return findCoder(err)
+}
+
+// findCoder returns the most recent error in the stack of err with a
+// non-zero HTTP response code, or nil.
+func findCoder(err error) coderError {
+	found := findError(err, func(err error) bool {
+		e, ok := err.(coder)
+		return ok && e.Code() != 0
+	})
+	if found == nil {
+		return nil
 	}
-	return ok
+	return found.(coderError)
 }
@@ -0,0 +1,815 @@
+// Code generated by gen_errortypes.go; DO NOT EDIT.
+
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo
+
+import (
+	"net/http"
+)
+
+// BadRequest represents an error when a request has bad parameters.
+
+// BadRequestf returns an error which satisfies IsBadRequest().
+func BadRequestf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusBadRequest, format, "", args...)
+	return &e
+}
+
+// NewBadRequest returns an error which wraps err that satisfies
+// IsBadRequest().
+func NewBadRequest(err error, msg string) error {
+	e := wrap(err, http.StatusBadRequest, msg, "")
+	return &e
+}
+
+// IsBadRequest reports whether err was created with BadRequestf() or
+// NewBadRequest(), or otherwise has their HTTP code as returned by Code.
+func IsBadRequest(err error) bool {
+	return hasCode(err, http.StatusBadRequest)
+}
+
+// Unauthorized represents an error when an operation is unauthorized.
+
+// Unauthorizedf returns an error which satisfies IsUnauthorized().
+func Unauthorizedf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusUnauthorized, format, "", args...)
+	return &e
+}
+
+// NewUnauthorized returns an error which wraps err that satisfies
+// IsUnauthorized().
+func NewUnauthorized(err error, msg string) error {
+	e := wrap(err, http.StatusUnauthorized, msg, "")
+	return &e
+}
+
+// IsUnauthorized reports whether err was created with Unauthorizedf() or
+// NewUnauthorized(), or otherwise has their HTTP code as returned by Code.
+func IsUnauthorized(err error) bool {
+	return hasCode(err, http.StatusUnauthorized)
+}
+
+// PaymentRequired represents an error when payment is required before the
+// request can be processed.
+
+// PaymentRequiredf returns an error which satisfies IsPaymentRequired().
+func PaymentRequiredf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusPaymentRequired, format, "", args...)
+	return &e
+}
+
+// NewPaymentRequired returns an error which wraps err that satisfies
+// IsPaymentRequired().
+func NewPaymentRequired(err error, msg string) error {
+	e := wrap(err, http.StatusPaymentRequired, msg, "")
+	return &e
+}
+
+// IsPaymentRequired reports whether err was created with PaymentRequiredf() or
+// NewPaymentRequired(), or otherwise has their HTTP code as returned by Code.
+func IsPaymentRequired(err error) bool {
+	return hasCode(err, http.StatusPaymentRequired)
+}
+
+// Forbidden represents an error when an operation is forbidden to an
+// authenticated client.
+
+// Forbiddenf returns an error which satisfies IsForbidden().
+func Forbiddenf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusForbidden, format, "", args...)
+	return &e
+}
+
+// NewForbidden returns an error which wraps err that satisfies
+// IsForbidden().
+func NewForbidden(err error, msg string) error {
+	e := wrap(err, http.StatusForbidden, msg, "")
+	return &e
+}
+
+// IsForbidden reports whether err was created with Forbiddenf() or
+// NewForbidden(), or otherwise has their HTTP code as returned by Code.
+func IsForbidden(err error) bool {
+	return hasCode(err, http.StatusForbidden)
+}
+
+// NotFound represents an error when something has not been found.
+
+// NotFoundf returns an error which satisfies IsNotFound().
+func NotFoundf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusNotFound, format, "", args...)
+	return &e
+}
+
+// NewNotFound returns an error which wraps err that satisfies
+// IsNotFound().
+func NewNotFound(err error, msg string) error {
+	e := wrap(err, http.StatusNotFound, msg, "")
+	return &e
+}
+
+// IsNotFound reports whether err was created with NotFoundf() or
+// NewNotFound(), or otherwise has their HTTP code as returned by Code.
+func IsNotFound(err error) bool {
+	return hasCode(err, http.StatusNotFound)
+}
+
+// MethodNotAllowed represents an error when an HTTP request
+// is made with an inappropriate method.
+
+// MethodNotAllowedf returns an error which satisfies IsMethodNotAllowed().
+func MethodNotAllowedf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusMethodNotAllowed, format, "", args...)
+	return &e
+}
+
+// NewMethodNotAllowed returns an error which wraps err that satisfies
+// IsMethodNotAllowed().
+func NewMethodNotAllowed(err error, msg string) error {
+	e := wrap(err, http.StatusMethodNotAllowed, msg, "")
+	return &e
+}
+
+// IsMethodNotAllowed reports whether err was created with MethodNotAllowedf() or
+// NewMethodNotAllowed(), or otherwise has their HTTP code as returned by Code.
+func IsMethodNotAllowed(err error) bool {
+	return hasCode(err, http.StatusMethodNotAllowed)
+}
+
+// NotAcceptable represents an error when no representation acceptable to the
+// client is available.
+
+// NotAcceptablef returns an error which satisfies IsNotAcceptable().
+func NotAcceptablef(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusNotAcceptable, format, "", args...)
+	return &e
+}
+
+// NewNotAcceptable returns an error which wraps err that satisfies
+// IsNotAcceptable().
+func NewNotAcceptable(err error, msg string) error {
+	e := wrap(err, http.StatusNotAcceptable, msg, "")
+	return &e
+}
+
+// IsNotAcceptable reports whether err was created with NotAcceptablef() or
+// NewNotAcceptable(), or otherwise has their HTTP code as returned by Code.
+func IsNotAcceptable(err error) bool {
+	return hasCode(err, http.StatusNotAcceptable)
+}
+
+// RequestTimeout represents an error when the client did not complete its
+// request in time.
+
+// RequestTimeoutf returns an error which satisfies IsRequestTimeout().
+func RequestTimeoutf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusRequestTimeout, format, "", args...)
+	return &e
+}
+
+// NewRequestTimeout returns an error which wraps err that satisfies
+// IsRequestTimeout().
+func NewRequestTimeout(err error, msg string) error {
+	e := wrap(err, http.StatusRequestTimeout, msg, "")
+	return &e
+}
+
+// IsRequestTimeout reports whether err was created with RequestTimeoutf() or
+// NewRequestTimeout(), or otherwise has their HTTP code as returned by Code.
+func IsRequestTimeout(err error) bool {
+	return hasCode(err, http.StatusRequestTimeout)
+}
+
+// Conflict represents an error when a request conflicts with the current
+// state of a resource.
+
+// Conflictf returns an error which satisfies IsConflict().
+func Conflictf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusConflict, format, "", args...)
+	return &e
+}
+
+// NewConflict returns an error which wraps err that satisfies
+// IsConflict().
+func NewConflict(err error, msg string) error {
+	e := wrap(err, http.StatusConflict, msg, "")
+	return &e
+}
+
+// IsConflict reports whether err was created with Conflictf() or
+// NewConflict(), or otherwise has their HTTP code as returned by Code.
+func IsConflict(err error) bool {
+	return hasCode(err, http.StatusConflict)
+}
+
+// Gone represents an error when a resource is permanently gone.
+
+// Gonef returns an error which satisfies IsGone().
+func Gonef(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusGone, format, "", args...)
+	return &e
+}
+
+// NewGone returns an error which wraps err that satisfies
+// IsGone().
+func NewGone(err error, msg string) error {
+	e := wrap(err, http.StatusGone, msg, "")
+	return &e
+}
+
+// IsGone reports whether err was created with Gonef() or
+// NewGone(), or otherwise has their HTTP code as returned by Code.
+func IsGone(err error) bool {
+	return hasCode(err, http.StatusGone)
+}
+
+// LengthRequired represents an error when a request is missing its content
+// length.
+
+// LengthRequiredf returns an error which satisfies IsLengthRequired().
+func LengthRequiredf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusLengthRequired, format, "", args...)
+	return &e
+}
+
+// NewLengthRequired returns an error which wraps err that satisfies
+// IsLengthRequired().
+func NewLengthRequired(err error, msg string) error {
+	e := wrap(err, http.StatusLengthRequired, msg, "")
+	return &e
+}
+
+// IsLengthRequired reports whether err was created with LengthRequiredf() or
+// NewLengthRequired(), or otherwise has their HTTP code as returned by Code.
+func IsLengthRequired(err error) bool {
+	return hasCode(err, http.StatusLengthRequired)
+}
+
+// PreconditionFailed represents an error when a request precondition
+// does not hold.
+
+// PreconditionFailedf returns an error which satisfies IsPreconditionFailed().
+func PreconditionFailedf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusPreconditionFailed, format, "", args...)
+	return &e
+}
+
+// NewPreconditionFailed returns an error which wraps err that satisfies
+// IsPreconditionFailed().
+func NewPreconditionFailed(err error, msg string) error {
+	e := wrap(err, http.StatusPreconditionFailed, msg, "")
+	return &e
+}
+
+// IsPreconditionFailed reports whether err was created with PreconditionFailedf() or
+// NewPreconditionFailed(), or otherwise has their HTTP code as returned by Code.
+func IsPreconditionFailed(err error) bool {
+	return hasCode(err, http.StatusPreconditionFailed)
+}
+
+// PayloadTooLarge represents an error when a request body is too large.
+
+// PayloadTooLargef returns an error which satisfies IsPayloadTooLarge().
+func PayloadTooLargef(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusRequestEntityTooLarge, format, "", args...)
+	return &e
+}
+
+// NewPayloadTooLarge returns an error which wraps err that satisfies
+// IsPayloadTooLarge().
+func NewPayloadTooLarge(err error, msg string) error {
+	e := wrap(err, http.StatusRequestEntityTooLarge, msg, "")
+	return &e
+}
+
+// IsPayloadTooLarge reports whether err was created with PayloadTooLargef() or
+// NewPayloadTooLarge(), or otherwise has their HTTP code as returned by Code.
+func IsPayloadTooLarge(err error) bool {
+	return hasCode(err, http.StatusRequestEntityTooLarge)
+}
+
+// URITooLong represents an error when a request URI is too long.
+
+// URITooLongf returns an error which satisfies IsURITooLong().
+func URITooLongf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusRequestURITooLong, format, "", args...)
+	return &e
+}
+
+// NewURITooLong returns an error which wraps err that satisfies
+// IsURITooLong().
+func NewURITooLong(err error, msg string) error {
+	e := wrap(err, http.StatusRequestURITooLong, msg, "")
+	return &e
+}
+
+// IsURITooLong reports whether err was created with URITooLongf() or
+// NewURITooLong(), or otherwise has their HTTP code as returned by Code.
+func IsURITooLong(err error) bool {
+	return hasCode(err, http.StatusRequestURITooLong)
+}
+
+// UnsupportedMediaType represents an error when a request body has an
+// unsupported media type.
+
+// UnsupportedMediaTypef returns an error which satisfies IsUnsupportedMediaType().
+func UnsupportedMediaTypef(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusUnsupportedMediaType, format, "", args...)
+	return &e
+}
+
+// NewUnsupportedMediaType returns an error which wraps err that satisfies
+// IsUnsupportedMediaType().
+func NewUnsupportedMediaType(err error, msg string) error {
+	e := wrap(err, http.StatusUnsupportedMediaType, msg, "")
+	return &e
+}
+
+// IsUnsupportedMediaType reports whether err was created with UnsupportedMediaTypef() or
+// NewUnsupportedMediaType(), or otherwise has their HTTP code as returned by Code.
+func IsUnsupportedMediaType(err error) bool {
+	return hasCode(err, http.StatusUnsupportedMediaType)
+}
+
+// RangeNotSatisfiable represents an error when a requested range
+// cannot be satisfied.
+
+// RangeNotSatisfiablef returns an error which satisfies IsRangeNotSatisfiable().
+func RangeNotSatisfiablef(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusRequestedRangeNotSatisfiable, format, "", args...)
+	return &e
+}
+
+// NewRangeNotSatisfiable returns an error which wraps err that satisfies
+// IsRangeNotSatisfiable().
+func NewRangeNotSatisfiable(err error, msg string) error {
+	e := wrap(err, http.StatusRequestedRangeNotSatisfiable, msg, "")
+	return &e
+}
+
+// IsRangeNotSatisfiable reports whether err was created with RangeNotSatisfiablef() or
+// NewRangeNotSatisfiable(), or otherwise has their HTTP code as returned by Code.
+func IsRangeNotSatisfiable(err error) bool {
+	return hasCode(err, http.StatusRequestedRangeNotSatisfiable)
+}
+
+// ExpectationFailed represents an error when the Expect header of a
+// request cannot be met.
+
+// ExpectationFailedf returns an error which satisfies IsExpectationFailed().
+func ExpectationFailedf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusExpectationFailed, format, "", args...)
+	return &e
+}
+
+// NewExpectationFailed returns an error which wraps err that satisfies
+// IsExpectationFailed().
+func NewExpectationFailed(err error, msg string) error {
+	e := wrap(err, http.StatusExpectationFailed, msg, "")
+	return &e
+}
+
+// IsExpectationFailed reports whether err was created with ExpectationFailedf() or
+// NewExpectationFailed(), or otherwise has their HTTP code as returned by Code.
+func IsExpectationFailed(err error) bool {
+	return hasCode(err, http.StatusExpectationFailed)
+}
+
+// MisdirectedRequest represents an error when a request was sent to a
+// server unable to respond to it.
+
+// MisdirectedRequestf returns an error which satisfies IsMisdirectedRequest().
+func MisdirectedRequestf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusMisdirectedRequest, format, "", args...)
+	return &e
+}
+
+// NewMisdirectedRequest returns an error which wraps err that satisfies
+// IsMisdirectedRequest().
+func NewMisdirectedRequest(err error, msg string) error {
+	e := wrap(err, http.StatusMisdirectedRequest, msg, "")
+	return &e
+}
+
+// IsMisdirectedRequest reports whether err was created with MisdirectedRequestf() or
+// NewMisdirectedRequest(), or otherwise has their HTTP code as returned by Code.
+func IsMisdirectedRequest(err error) bool {
+	return hasCode(err, http.StatusMisdirectedRequest)
+}
+
+// UnprocessableEntity represents an error when a well formed request
+// cannot be processed because of its content.
+
+// UnprocessableEntityf returns an error which satisfies IsUnprocessableEntity().
+func UnprocessableEntityf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusUnprocessableEntity, format, "", args...)
+	return &e
+}
+
+// NewUnprocessableEntity returns an error which wraps err that satisfies
+// IsUnprocessableEntity().
+func NewUnprocessableEntity(err error, msg string) error {
+	e := wrap(err, http.StatusUnprocessableEntity, msg, "")
+	return &e
+}
+
+// IsUnprocessableEntity reports whether err was created with UnprocessableEntityf() or
+// NewUnprocessableEntity(), or otherwise has their HTTP code as returned by Code.
+func IsUnprocessableEntity(err error) bool {
+	return hasCode(err, http.StatusUnprocessableEntity)
+}
+
+// Locked represents an error when a resource is locked.
+
+// Lockedf returns an error which satisfies IsLocked().
+func Lockedf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusLocked, format, "", args...)
+	return &e
+}
+
+// NewLocked returns an error which wraps err that satisfies
+// IsLocked().
+func NewLocked(err error, msg string) error {
+	e := wrap(err, http.StatusLocked, msg, "")
+	return &e
+}
+
+// IsLocked reports whether err was created with Lockedf() or
+// NewLocked(), or otherwise has their HTTP code as returned by Code.
+func IsLocked(err error) bool {
+	return hasCode(err, http.StatusLocked)
+}
+
+// FailedDependency represents an error when a request failed because an
+// earlier request failed.
+
+// FailedDependencyf returns an error which satisfies IsFailedDependency().
+func FailedDependencyf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusFailedDependency, format, "", args...)
+	return &e
+}
+
+// NewFailedDependency returns an error which wraps err that satisfies
+// IsFailedDependency().
+func NewFailedDependency(err error, msg string) error {
+	e := wrap(err, http.StatusFailedDependency, msg, "")
+	return &e
+}
+
+// IsFailedDependency reports whether err was created with FailedDependencyf() or
+// NewFailedDependency(), or otherwise has their HTTP code as returned by Code.
+func IsFailedDependency(err error) bool {
+	return hasCode(err, http.StatusFailedDependency)
+}
+
+// TooEarly represents an error when a request might be replayed.
+
+// TooEarlyf returns an error which satisfies IsTooEarly().
+func TooEarlyf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusTooEarly, format, "", args...)
+	return &e
+}
+
+// NewTooEarly returns an error which wraps err that satisfies
+// IsTooEarly().
+func NewTooEarly(err error, msg string) error {
+	e := wrap(err, http.StatusTooEarly, msg, "")
+	return &e
+}
+
+// IsTooEarly reports whether err was created with TooEarlyf() or
+// NewTooEarly(), or otherwise has their HTTP code as returned by Code.
+func IsTooEarly(err error) bool {
+	return hasCode(err, http.StatusTooEarly)
+}
+
+// UpgradeRequired represents an error when the client must switch to a
+// different protocol.
+
+// UpgradeRequiredf returns an error which satisfies IsUpgradeRequired().
+func UpgradeRequiredf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusUpgradeRequired, format, "", args...)
+	return &e
+}
+
+// NewUpgradeRequired returns an error which wraps err that satisfies
+// IsUpgradeRequired().
+func NewUpgradeRequired(err error, msg string) error {
+	e := wrap(err, http.StatusUpgradeRequired, msg, "")
+	return &e
+}
+
+// IsUpgradeRequired reports whether err was created with UpgradeRequiredf() or
+// NewUpgradeRequired(), or otherwise has their HTTP code as returned by Code.
+func IsUpgradeRequired(err error) bool {
+	return hasCode(err, http.StatusUpgradeRequired)
+}
+
+// PreconditionRequired represents an error when a request must be
+// conditional.
+
+// PreconditionRequiredf returns an error which satisfies IsPreconditionRequired().
+func PreconditionRequiredf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusPreconditionRequired, format, "", args...)
+	return &e
+}
+
+// NewPreconditionRequired returns an error which wraps err that satisfies
+// IsPreconditionRequired().
+func NewPreconditionRequired(err error, msg string) error {
+	e := wrap(err, http.StatusPreconditionRequired, msg, "")
+	return &e
+}
+
+// IsPreconditionRequired reports whether err was created with PreconditionRequiredf() or
+// NewPreconditionRequired(), or otherwise has their HTTP code as returned by Code.
+func IsPreconditionRequired(err error) bool {
+	return hasCode(err, http.StatusPreconditionRequired)
+}
+
+// TooManyRequests represents an error when a client has sent too many
+// requests.
+
+// TooManyRequestsf returns an error which satisfies IsTooManyRequests().
+func TooManyRequestsf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusTooManyRequests, format, "", args...)
+	return &e
+}
+
+// NewTooManyRequests returns an error which wraps err that satisfies
+// IsTooManyRequests().
+func NewTooManyRequests(err error, msg string) error {
+	e := wrap(err, http.StatusTooManyRequests, msg, "")
+	return &e
+}
+
+// IsTooManyRequests reports whether err was created with TooManyRequestsf() or
+// NewTooManyRequests(), or otherwise has their HTTP code as returned by Code.
+func IsTooManyRequests(err error) bool {
+	return hasCode(err, http.StatusTooManyRequests)
+}
+
+// RequestHeaderFieldsTooLarge represents an error when the
+// request header fields are too large.
+
+// RequestHeaderFieldsTooLargef returns an error which satisfies IsRequestHeaderFieldsTooLarge().
+func RequestHeaderFieldsTooLargef(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusRequestHeaderFieldsTooLarge, format, "", args...)
+	return &e
+}
+
+// NewRequestHeaderFieldsTooLarge returns an error which wraps err that satisfies
+// IsRequestHeaderFieldsTooLarge().
+func NewRequestHeaderFieldsTooLarge(err error, msg string) error {
+	e := wrap(err, http.StatusRequestHeaderFieldsTooLarge, msg, "")
+	return &e
+}
+
+// IsRequestHeaderFieldsTooLarge reports whether err was created with RequestHeaderFieldsTooLargef() or
+// NewRequestHeaderFieldsTooLarge(), or otherwise has their HTTP code as returned by Code.
+func IsRequestHeaderFieldsTooLarge(err error) bool {
+	return hasCode(err, http.StatusRequestHeaderFieldsTooLarge)
+}
+
+// UnavailableForLegalReasons represents an error when a
+// resource cannot be provided for legal reasons.
+
+// UnavailableForLegalReasonsf returns an error which satisfies IsUnavailableForLegalReasons().
+func UnavailableForLegalReasonsf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusUnavailableForLegalReasons, format, "", args...)
+	return &e
+}
+
+// NewUnavailableForLegalReasons returns an error which wraps err that satisfies
+// IsUnavailableForLegalReasons().
+func NewUnavailableForLegalReasons(err error, msg string) error {
+	e := wrap(err, http.StatusUnavailableForLegalReasons, msg, "")
+	return &e
+}
+
+// IsUnavailableForLegalReasons reports whether err was created with UnavailableForLegalReasonsf() or
+// NewUnavailableForLegalReasons(), or otherwise has their HTTP code as returned by Code.
+func IsUnavailableForLegalReasons(err error) bool {
+	return hasCode(err, http.StatusUnavailableForLegalReasons)
+}
+
+// InternalServer represents an error when something unexpected has happened.
+
+// InternalServerf returns an error which satisfies IsInternalServer().
+func InternalServerf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusInternalServerError, format, "", args...)
+	return &e
+}
+
+// NewInternalServer returns an error which wraps err that satisfies
+// IsInternalServer().
+func NewInternalServer(err error, msg string) error {
+	e := wrap(err, http.StatusInternalServerError, msg, "")
+	return &e
+}
+
+// IsInternalServer reports whether err was created with InternalServerf() or
+// NewInternalServer(), or otherwise has their HTTP code as returned by Code.
+func IsInternalServer(err error) bool {
+	return hasCode(err, http.StatusInternalServerError)
+}
+
+// NotImplemented represents an error when something is not
+// implemented.
+
+// NotImplementedf returns an error which satisfies IsNotImplemented().
+func NotImplementedf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusNotImplemented, format, "", args...)
+	return &e
+}
+
+// NewNotImplemented returns an error which wraps err that satisfies
+// IsNotImplemented().
+func NewNotImplemented(err error, msg string) error {
+	e := wrap(err, http.StatusNotImplemented, msg, "")
+	return &e
+}
+
+// IsNotImplemented reports whether err was created with NotImplementedf() or
+// NewNotImplemented(), or otherwise has their HTTP code as returned by Code.
+func IsNotImplemented(err error) bool {
+	return hasCode(err, http.StatusNotImplemented)
+}
+
+// BadGateway represents an error when an upstream server returned an
+// invalid response.
+
+// BadGatewayf returns an error which satisfies IsBadGateway().
+func BadGatewayf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusBadGateway, format, "", args...)
+	return &e
+}
+
+// NewBadGateway returns an error which wraps err that satisfies
+// IsBadGateway().
+func NewBadGateway(err error, msg string) error {
+	e := wrap(err, http.StatusBadGateway, msg, "")
+	return &e
+}
+
+// IsBadGateway reports whether err was created with BadGatewayf() or
+// NewBadGateway(), or otherwise has their HTTP code as returned by Code.
+func IsBadGateway(err error) bool {
+	return hasCode(err, http.StatusBadGateway)
+}
+
+// ServiceUnavailable represents an error when a service is temporarily
+// unavailable.
+
+// ServiceUnavailablef returns an error which satisfies IsServiceUnavailable().
+func ServiceUnavailablef(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusServiceUnavailable, format, "", args...)
+	return &e
+}
+
+// NewServiceUnavailable returns an error which wraps err that satisfies
+// IsServiceUnavailable().
+func NewServiceUnavailable(err error, msg string) error {
+	e := wrap(err, http.StatusServiceUnavailable, msg, "")
+	return &e
+}
+
+// IsServiceUnavailable reports whether err was created with ServiceUnavailablef() or
+// NewServiceUnavailable(), or otherwise has their HTTP code as returned by Code.
+func IsServiceUnavailable(err error) bool {
+	return hasCode(err, http.StatusServiceUnavailable)
+}
+
+// GatewayTimeout represents an error when an upstream server did not
+// respond in time.
+
+// GatewayTimeoutf returns an error which satisfies IsGatewayTimeout().
+func GatewayTimeoutf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusGatewayTimeout, format, "", args...)
+	return &e
+}
+
+// NewGatewayTimeout returns an error which wraps err that satisfies
+// IsGatewayTimeout().
+func NewGatewayTimeout(err error, msg string) error {
+	e := wrap(err, http.StatusGatewayTimeout, msg, "")
+	return &e
+}
+
+// IsGatewayTimeout reports whether err was created with GatewayTimeoutf() or
+// NewGatewayTimeout(), or otherwise has their HTTP code as returned by Code.
+func IsGatewayTimeout(err error) bool {
+	return hasCode(err, http.StatusGatewayTimeout)
+}
+
+// HTTPVersionNotSupported represents an error when the HTTP
+// version of a request is not supported.
+
+// HTTPVersionNotSupportedf returns an error which satisfies IsHTTPVersionNotSupported().
+func HTTPVersionNotSupportedf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusHTTPVersionNotSupported, format, "", args...)
+	return &e
+}
+
+// NewHTTPVersionNotSupported returns an error which wraps err that satisfies
+// IsHTTPVersionNotSupported().
+func NewHTTPVersionNotSupported(err error, msg string) error {
+	e := wrap(err, http.StatusHTTPVersionNotSupported, msg, "")
+	return &e
+}
+
+// IsHTTPVersionNotSupported reports whether err was created with HTTPVersionNotSupportedf() or
+// NewHTTPVersionNotSupported(), or otherwise has their HTTP code as returned by Code.
+func IsHTTPVersionNotSupported(err error) bool {
+	return hasCode(err, http.StatusHTTPVersionNotSupported)
+}
+
+// InsufficientStorage represents an error when there is not enough
+// storage to complete a request.
+
+// InsufficientStoragef returns an error which satisfies IsInsufficientStorage().
+func InsufficientStoragef(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusInsufficientStorage, format, "", args...)
+	return &e
+}
+
+// NewInsufficientStorage returns an error which wraps err that satisfies
+// IsInsufficientStorage().
+func NewInsufficientStorage(err error, msg string) error {
+	e := wrap(err, http.StatusInsufficientStorage, msg, "")
+	return &e
+}
+
+// IsInsufficientStorage reports whether err was created with InsufficientStoragef() or
+// NewInsufficientStorage(), or otherwise has their HTTP code as returned by Code.
+func IsInsufficientStorage(err error) bool {
+	return hasCode(err, http.StatusInsufficientStorage)
+}
+
+// LoopDetected represents an error when an infinite loop was detected
+// while processing a request.
+
+// LoopDetectedf returns an error which satisfies IsLoopDetected().
+func LoopDetectedf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusLoopDetected, format, "", args...)
+	return &e
+}
+
+// NewLoopDetected returns an error which wraps err that satisfies
+// IsLoopDetected().
+func NewLoopDetected(err error, msg string) error {
+	e := wrap(err, http.StatusLoopDetected, msg, "")
+	return &e
+}
+
+// IsLoopDetected reports whether err was created with LoopDetectedf() or
+// NewLoopDetected(), or otherwise has their HTTP code as returned by Code.
+func IsLoopDetected(err error) bool {
+	return hasCode(err, http.StatusLoopDetected)
+}
+
+// NotExtended represents an error when further extensions to a request
+// are required.
+
+// NotExtendedf returns an error which satisfies IsNotExtended().
+func NotExtendedf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusNotExtended, format, "", args...)
+	return &e
+}
+
+// NewNotExtended returns an error which wraps err that satisfies
+// IsNotExtended().
+func NewNotExtended(err error, msg string) error {
+	e := wrap(err, http.StatusNotExtended, msg, "")
+	return &e
+}
+
+// IsNotExtended reports whether err was created with NotExtendedf() or
+// NewNotExtended(), or otherwise has their HTTP code as returned by Code.
+func IsNotExtended(err error) bool {
+	return hasCode(err, http.StatusNotExtended)
+}
+
+// NetworkAuthenticationRequired represents an error when the
+// client needs to authenticate to gain network access.
+
+// NetworkAuthenticationRequiredf returns an error which satisfies IsNetworkAuthenticationRequired().
+func NetworkAuthenticationRequiredf(format string, args ...interface{}) error {
+	e := wrap(nil, http.StatusNetworkAuthenticationRequired, format, "", args...)
+	return &e
+}
+
+// NewNetworkAuthenticationRequired returns an error which wraps err that satisfies
+// IsNetworkAuthenticationRequired().
+func NewNetworkAuthenticationRequired(err error, msg string) error {
+	e := wrap(err, http.StatusNetworkAuthenticationRequired, msg, "")
+	return &e
+}
+
+// IsNetworkAuthenticationRequired reports whether err was created with NetworkAuthenticationRequiredf() or
+// NewNetworkAuthenticationRequired(), or otherwise has their HTTP code as returned by Code.
+func IsNetworkAuthenticationRequired(err error) bool {
+	return hasCode(err, http.StatusNetworkAuthenticationRequired)
+}
@@ -6,6 +6,8 @@ package errgo_test
 import (
 	stderrors "errors"
 	"fmt"
+	"io"
+	"net/http"
 	"reflect"
 	"runtime"
 
@@ -15,25 +17,56 @@ import (
 )
 
 // errorInfo holds information about a single error type: a satisfier
-// function, wrapping and variable arguments constructors and message
-// suffix.
+// function, wrapping and variable arguments constructors, message
+// suffix and HTTP response code.
 type errorInfo struct {
 	satisfier       func(error) bool
 	argsConstructor func(string, ...interface{}) error
 	wrapConstructor func(error, string) error
 	suffix          string
+	code            int
 }
 
 // allErrors holds information for all defined errors. When adding new
 // errors, add them here as well to include them in tests.
 var allErrors = []*errorInfo{
-	&errorInfo{errgo.IsBadRequest, errgo.BadRequestf, errgo.NewBadRequest, ""},
-	&errorInfo{errgo.IsInternalServer, errgo.InternalServerf, errgo.NewInternalServer, ""},
-	&errorInfo{errgo.IsNotFound, errgo.NotFoundf, errgo.NewNotFound, ""},
-	&errorInfo{errgo.IsUnauthorized, errgo.Unauthorizedf, errgo.NewUnauthorized, ""},
-	&errorInfo{errgo.IsNotImplemented, errgo.NotImplementedf, errgo.NewNotImplemented, ""},
-	&errorInfo{errgo.IsBadRequest, errgo.BadRequestf, errgo.NewBadRequest, ""},
-	&errorInfo{errgo.IsMethodNotAllowed, errgo.MethodNotAllowedf, errgo.NewMethodNotAllowed, ""},
+	&errorInfo{errgo.IsBadRequest, errgo.BadRequestf, errgo.NewBadRequest, "", http.StatusBadRequest},
+	&errorInfo{errgo.IsUnauthorized, errgo.Unauthorizedf, errgo.NewUnauthorized, "", http.StatusUnauthorized},
+	&errorInfo{errgo.IsPaymentRequired, errgo.PaymentRequiredf, errgo.NewPaymentRequired, "", http.StatusPaymentRequired},
+	&errorInfo{errgo.IsForbidden, errgo.Forbiddenf, errgo.NewForbidden, "", http.StatusForbidden},
+	&errorInfo{errgo.IsNotFound, errgo.NotFoundf, errgo.NewNotFound, "", http.StatusNotFound},
+	&errorInfo{errgo.IsMethodNotAllowed, errgo.MethodNotAllowedf, errgo.NewMethodNotAllowed, "", http.StatusMethodNotAllowed},
+	&errorInfo{errgo.IsNotAcceptable, errgo.NotAcceptablef, errgo.NewNotAcceptable, "", http.StatusNotAcceptable},
+	&errorInfo{errgo.IsRequestTimeout, errgo.RequestTimeoutf, errgo.NewRequestTimeout, "", http.StatusRequestTimeout},
+	&errorInfo{errgo.IsConflict, errgo.Conflictf, errgo.NewConflict, "", http.StatusConflict},
+	&errorInfo{errgo.IsGone, errgo.Gonef, errgo.NewGone, "", http.StatusGone},
+	&errorInfo{errgo.IsLengthRequired, errgo.LengthRequiredf, errgo.NewLengthRequired, "", http.StatusLengthRequired},
+	&errorInfo{errgo.IsPreconditionFailed, errgo.PreconditionFailedf, errgo.NewPreconditionFailed, "", http.StatusPreconditionFailed},
+	&errorInfo{errgo.IsPayloadTooLarge, errgo.PayloadTooLargef, errgo.NewPayloadTooLarge, "", http.StatusRequestEntityTooLarge},
+	&errorInfo{errgo.IsURITooLong, errgo.URITooLongf, errgo.NewURITooLong, "", http.StatusRequestURITooLong},
+	&errorInfo{errgo.IsUnsupportedMediaType, errgo.UnsupportedMediaTypef, errgo.NewUnsupportedMediaType, "", http.StatusUnsupportedMediaType},
+	&errorInfo{errgo.IsRangeNotSatisfiable, errgo.RangeNotSatisfiablef, errgo.NewRangeNotSatisfiable, "", http.StatusRequestedRangeNotSatisfiable},
+	&errorInfo{errgo.IsExpectationFailed, errgo.ExpectationFailedf, errgo.NewExpectationFailed, "", http.StatusExpectationFailed},
+	&errorInfo{errgo.IsMisdirectedRequest, errgo.MisdirectedRequestf, errgo.NewMisdirectedRequest, "", http.StatusMisdirectedRequest},
+	&errorInfo{errgo.IsUnprocessableEntity, errgo.UnprocessableEntityf, errgo.NewUnprocessableEntity, "", http.StatusUnprocessableEntity},
+	&errorInfo{errgo.IsLocked, errgo.Lockedf, errgo.NewLocked, "", http.StatusLocked},
+	&errorInfo{errgo.IsFailedDependency, errgo.FailedDependencyf, errgo.NewFailedDependency, "", http.StatusFailedDependency},
+	&errorInfo{errgo.IsTooEarly, errgo.TooEarlyf, errgo.NewTooEarly, "", http.StatusTooEarly},
+	&errorInfo{errgo.IsUpgradeRequired, errgo.UpgradeRequiredf, errgo.NewUpgradeRequired, "", http.StatusUpgradeRequired},
+	&errorInfo{errgo.IsPreconditionRequired, errgo.PreconditionRequiredf, errgo.NewPreconditionRequired, "", http.StatusPreconditionRequired},
+	&errorInfo{errgo.IsTooManyRequests, errgo.TooManyRequestsf, errgo.NewTooManyRequests, "", http.StatusTooManyRequests},
+	&errorInfo{errgo.IsRequestHeaderFieldsTooLarge, errgo.RequestHeaderFieldsTooLargef, errgo.NewRequestHeaderFieldsTooLarge, "", http.StatusRequestHeaderFieldsTooLarge},
+	&errorInfo{errgo.IsUnavailableForLegalReasons, errgo.UnavailableForLegalReasonsf, errgo.NewUnavailableForLegalReasons, "", http.StatusUnavailableForLegalReasons},
+	&errorInfo{errgo.IsInternalServer, errgo.InternalServerf, errgo.NewInternalServer, "", http.StatusInternalServerError},
+	&errorInfo{errgo.IsNotImplemented, errgo.NotImplementedf, errgo.NewNotImplemented, "", http.StatusNotImplemented},
+	&errorInfo{errgo.IsBadGateway, errgo.BadGatewayf, errgo.NewBadGateway, "", http.StatusBadGateway},
+	&errorInfo{errgo.IsServiceUnavailable, errgo.ServiceUnavailablef, errgo.NewServiceUnavailable, "", http.StatusServiceUnavailable},
+	&errorInfo{errgo.IsGatewayTimeout, errgo.GatewayTimeoutf, errgo.NewGatewayTimeout, "", http.StatusGatewayTimeout},
+	&errorInfo{errgo.IsHTTPVersionNotSupported, errgo.HTTPVersionNotSupportedf, errgo.NewHTTPVersionNotSupported, "", http.StatusHTTPVersionNotSupported},
+	&errorInfo{errgo.IsInsufficientStorage, errgo.InsufficientStoragef, errgo.NewInsufficientStorage, "", http.StatusInsufficientStorage},
+	&errorInfo{errgo.IsLoopDetected, errgo.LoopDetectedf, errgo.NewLoopDetected, "", http.StatusLoopDetected},
+	&errorInfo{errgo.IsNotExtended, errgo.NotExtendedf, errgo.NewNotExtended, "", http.StatusNotExtended},
+	&errorInfo{errgo.IsNetworkAuthenticationRequired, errgo.NetworkAuthenticationRequiredf, errgo.NewNetworkAuthenticationRequired, "", http.StatusNetworkAuthenticationRequired},
 }
 
 type errorTypeSuite struct{}
@@ -167,3 +200,171 @@ func (*errorTypeSuite) TestAllErrors(c *gc.C) {
 
 	runErrorTests(c, errorTests, true)
 }
+
+func (*errorTypeSuite) TestCodes(c *gc.C) {
+	codes := make(map[int]bool)
+	for _, errInfo := range allErrors {
+		c.Logf("%s", errInfo.satisfierName())
+		c.Check(codes[errInfo.code], jc.IsFalse)
+		codes[errInfo.code] = true
+		for _, err := range []error{
+			errInfo.argsConstructor("foo"),
+			errInfo.wrapConstructor(stderrors.New("pow!"), "prefix"),
+			errgo.Annotate(errInfo.argsConstructor("foo"), "annotation"),
+		} {
+			c.Check(errgo.Cause(err).(*errgo.Err).Code(), gc.Equals, errInfo.code)
+			c.Check(errgo.NewProblem(err).Status, gc.Equals, errInfo.code)
+		}
+	}
+}
+
+func (*errorTypeSuite) TestUnwrap(c *gc.C) {
+	for _, errInfo := range allErrors {
+		c.Logf("%s", errInfo.satisfierName())
+		err := errInfo.wrapConstructor(io.EOF, "prefix")
+		c.Check(stderrors.Is(err, io.EOF), jc.IsTrue)
+		c.Check(stderrors.Is(errgo.Annotate(err, "annotation"), io.EOF), jc.IsTrue)
+		c.Check(stderrors.Is(errgo.Mask(err), io.EOF), jc.IsFalse)
+
+		var target *errgo.Err
+		c.Check(stderrors.As(err, &target), jc.IsTrue)
+		c.Check(target, gc.Equals, err)
+		c.Check(target.Code(), gc.Not(gc.Equals), 0)
+
+		err = errInfo.argsConstructor("foo %d", 42)
+		c.Check(stderrors.Unwrap(err), gc.IsNil)
+		c.Check(stderrors.Is(err, io.EOF), jc.IsFalse)
+	}
+}
+
+func (*errorTypeSuite) TestStackFrames(c *gc.C) {
+	if runtime.Compiler == "gccgo" {
+		c.Skip("gccgo can't determine the location")
+	}
+	defer errgo.SetStackCapture(errgo.StackCapture())
+	errgo.SetStackCapture(true)
+	for _, errInfo := range allErrors {
+		c.Logf("%s", errInfo.satisfierName())
+		for _, err := range []error{
+			errInfo.argsConstructor("foo"),
+			errInfo.wrapConstructor(stderrors.New("pow!"), "prefix"),
+		} {
+			frames := err.(*errgo.Err).StackFrames()
+			c.Assert(frames, gc.Not(gc.HasLen), 0)
+			c.Check(frames[0].Function, gc.Equals, "github.com/hifx/errgo_test.(*errorTypeSuite).TestStackFrames")
+			c.Check(frames[0].File, gc.Equals, "github.com/hifx/errgo/errortypes_test.go")
+		}
+	}
+}
+
+// codedError is an error type from outside errgo with an HTTP code.
+type codedError struct {
+	code int
+}
+
+func (e codedError) Error() string {
+	return fmt.Sprintf("coded %d", e.code)
+}
+
+func (e codedError) Code() int {
+	return e.code
+}
+
+func (*errorTypeSuite) TestSatisfiersSearchStack(c *gc.C) {
+	for _, errInfo := range allErrors {
+		c.Logf("%s", errInfo.satisfierName())
+		errorTests := []errorTest{{
+			errgo.Wrap(errInfo.argsConstructor("foo"), stderrors.New("other")),
+			"other",
+			errInfo,
+		}, {
+			errgo.Annotate(errgo.Wrap(errInfo.wrapConstructor(io.EOF, "foo"), stderrors.New("other")), "bar"),
+			"bar: other",
+			errInfo,
+		}, {
+			errgo.Mask(errInfo.argsConstructor("foo")),
+			"foo",
+			errInfo,
+		}, {
+			errgo.Trace(errgo.Maskf(errInfo.argsConstructor("foo"), "masked")),
+			"masked: foo",
+			errInfo,
+		}, {
+			errgo.Wrap(io.EOF, errgo.Annotate(errInfo.argsConstructor("foo"), "bar")),
+			"bar: foo",
+			errInfo,
+		}, {
+			fmt.Errorf("wrapped: %w", errInfo.argsConstructor("foo")),
+			"wrapped: foo",
+			errInfo,
+		}, {
+			stderrors.Join(io.EOF, errgo.Trace(errInfo.argsConstructor("foo"))),
+			"EOF\nfoo",
+			errInfo,
+		}, {
+			errgo.Annotate(codedError{errInfo.code}, "bar"),
+			fmt.Sprintf("bar: coded %d", errInfo.code),
+			errInfo,
+		}, {
+			newEmbed("embedded"),
+			"embedded",
+			nil,
+		}, {
+			errgo.Annotate(codedError{0}, "bar"),
+			"bar: coded 0",
+			nil,
+		}}
+		runErrorTests(c, errorTests, true)
+	}
+}
+
+type embedWithCode struct {
+	errgo.Err
+}
+
+func (*errorTypeSuite) TestSatisfiersRecognizeEmbeddedErr(c *gc.C) {
+	err := &embedWithCode{errgo.NewErr(http.StatusGone, "gone away")}
+	c.Assert(errgo.IsGone(err), jc.IsTrue)
+	c.Assert(errgo.IsGone(errgo.Annotate(err, "fetching")), jc.IsTrue)
+	c.Assert(errgo.IsGone(errgo.Wrap(err, stderrors.New("other"))), jc.IsTrue)
+	c.Assert(errgo.Code(err), gc.Equals, http.StatusGone)
+}
+
+func (*errorTypeSuite) TestCodePrecedence(c *gc.C) {
+	for i, test := range []struct {
+		message string
+		err     error
+		code    int
+	}{{
+		message: "no code",
+		err:     errgo.Annotate(io.EOF, "bar"),
+		code:    0,
+	}, {
+		message: "nil",
+		err:     nil,
+		code:    0,
+	}, {
+		message: "most recent code wins",
+		err:     errgo.Annotate(errgo.NewBadRequest(errgo.NotFoundf("user"), "bad user"), "login"),
+		code:    http.StatusBadRequest,
+	}, {
+		message: "cause wins",
+		err:     errgo.Trace(errgo.Wrap(errgo.BadRequestf("bad user"), errgo.NotFoundf("user"))),
+		code:    http.StatusNotFound,
+	}, {
+		message: "cause without code",
+		err:     errgo.Wrap(errgo.BadRequestf("bad user"), io.EOF),
+		code:    http.StatusBadRequest,
+	}, {
+		message: "wrapping cause searched before replaced error",
+		err:     errgo.Wrap(errgo.BadRequestf("bad user"), errgo.Annotate(errgo.Gonef("user"), "bar")),
+		code:    http.StatusGone,
+	}, {
+		message: "mask over code",
+		err:     errgo.NewConflict(errgo.Mask(errgo.NotFoundf("user")), "conflict"),
+		code:    http.StatusConflict,
+	}} {
+		c.Logf("%v: %s", i, test.message)
+		c.Check(errgo.Code(test.err), gc.Equals, test.code)
+	}
+}
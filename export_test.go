@@ -3,10 +3,73 @@
 
 package errgo
 
-// Since variables are declared before the init block, in order to get the goPath
-// we need to return it rather than just reference it.
-func GoPath() string {
-	return goPath
+import (
+	"text/template"
+)
+
+var (
+	TrimSourcePath = trimSourcePath
+	PackagePath    = packagePath
+)
+
+// SetModulePaths replaces the module paths read from the build information,
+// returning a function that restores them.
+func SetModulePaths(paths []string) (restore func()) {
+	old := modulePaths
+	modulePaths = paths
+	return func() {
+		modulePaths = old
+	}
 }
 
-var TrimGoPath = trimGoPath
+// SetMainPackagePath replaces the import path of package main read from
+// the build information, returning a function that restores it.
+func SetMainPackagePath(path string) (restore func()) {
+	old := mainPackagePath
+	mainPackagePath = path
+	return func() {
+		mainPackagePath = old
+	}
+}
+
+// ResetRedactionRules clears the registered redaction rules, returning a
+// function that restores them.
+func ResetRedactionRules() (restore func()) {
+	rules.mu.Lock()
+	defer rules.mu.Unlock()
+	oldKeys, oldPatterns := rules.keys, rules.patterns
+	rules.keys, rules.patterns = make(map[string]bool), nil
+	return func() {
+		rules.mu.Lock()
+		defer rules.mu.Unlock()
+		rules.keys, rules.patterns = oldKeys, oldPatterns
+	}
+}
+
+// ResetTranslations clears the registered translations, returning a
+// function that restores them.
+func ResetTranslations() (restore func()) {
+	catalog.mu.Lock()
+	defer catalog.mu.Unlock()
+	old := catalog.translations
+	catalog.translations = make(map[string]map[string]*template.Template)
+	return func() {
+		catalog.mu.Lock()
+		defer catalog.mu.Unlock()
+		catalog.translations = old
+	}
+}
+
+// ResetContextValues clears the registered context values, returning a
+// function that restores them.
+func ResetContextValues() (restore func()) {
+	contextRegistry.Lock()
+	defer contextRegistry.Unlock()
+	old := contextRegistry.values
+	contextRegistry.values = nil
+	return func() {
+		contextRegistry.Lock()
+		defer contextRegistry.Unlock()
+		contextRegistry.values = old
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Field holds a key/value pair of context attached to an entry in the
+// error stack, such as a user ID or a request path, so that it can be
+// searched for in logs rather than being baked into the message.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F returns a Field with the given key and value.
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// String returns the field as key=value, quoting the value if needed.
+func (f Field) String() string {
+	value := fmt.Sprint(f.Value)
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = strconv.Quote(value)
+	}
+	return f.Key + "=" + value
+}
+
+// TraceWith is like Trace, but also attaches the given fields to the new
+// entry in the error stack.
+//
+// For example:
+//   if err := SomeFunc(); err != nil {
+//       return errgo.TraceWith(err, errgo.F("user", userID))
+//   }
+//
+func TraceWith(other error, fields ...Field) error {
+	if other == nil {
+		return nil
+	}
+	err := &Err{
+		previous: other,
+		cause:    Cause(other),
+		fields:   fields,
+	}
+	err.SetLocation(1)
+	return err
+}
+
+// AnnotateWith is like Annotate, but also attaches the given fields to the
+// new entry in the error stack.
+//
+// For example:
+//   if err := SomeFunc(); err != nil {
+//       return errgo.AnnotateWith(err, "failed to frombulate", errgo.F("path", r.URL.Path))
+//   }
+//
+func AnnotateWith(other error, message string, fields ...Field) error {
+	if other == nil {
+		return nil
+	}
+	err := &Err{
+		previous: other,
+		cause:    Cause(other),
+		message:  message,
+		fields:   fields,
+	}
+	err.SetLocation(1)
+	return err
+}
+
+// Fields returns the fields attached to this entry in the error stack.
+func (e *Err) Fields() []Field {
+	return e.fields
+}
+
+type fielder interface {
+	Fields() []Field
+}
+
+var _ fielder = (*Err)(nil)
+
+// Fields returns the fields attached to all the errors in the stack of
+// err, searched as for Code, merged into one list. Fields appear in the
+// order their keys were first attached, starting from the original error,
+// and where a key was attached more than once the most recent value is
+// used.
+func Fields(err error) []Field {
+	var stack []error
+	visitErrors(err, func(err error) {
+		stack = append(stack, err)
+	})
+	var result []Field
+	index := make(map[string]int)
+	for i := len(stack) - 1; i >= 0; i-- {
+		err, ok := stack[i].(fielder)
+		if !ok {
+			continue
+		}
+		for _, field := range err.Fields() {
+			if j, ok := index[field.Key]; ok {
+				result[j] = field
+				continue
+			}
+			index[field.Key] = len(result)
+			result = append(result, field)
+		}
+	}
+	return result
+}
+
+// appendFields appends the fields of err, if any, to buff as space
+// separated key=value pairs, redacted as for SafeFields if safe is true.
+func appendFields(buff []byte, err error, safe bool) []byte {
+	if err, ok := err.(fielder); ok {
+		for _, field := range err.Fields() {
+			if safe {
+				field = redactField(field)
+			}
+			buff = append(buff, ' ')
+			buff = append(buff, field.String()...)
+		}
+	}
+	return buff
+}
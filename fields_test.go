@@ -0,0 +1,129 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/hifx/errgo"
+)
+
+type fieldsSuite struct{}
+
+var _ = gc.Suite(&fieldsSuite{})
+
+func (*fieldsSuite) TestFieldString(c *gc.C) {
+	c.Assert(errgo.F("user", 42).String(), gc.Equals, "user=42")
+	c.Assert(errgo.F("path", "/users/42").String(), gc.Equals, "path=/users/42")
+	c.Assert(errgo.F("name", "Jo Bloggs").String(), gc.Equals, `name="Jo Bloggs"`)
+	c.Assert(errgo.F("query", "a=b").String(), gc.Equals, `query="a=b"`)
+	c.Assert(errgo.F("empty", "").String(), gc.Equals, `empty=""`)
+}
+
+func (*fieldsSuite) TestAnnotateWith(c *gc.C) {
+	first := errgo.New("first")
+	err := errgo.AnnotateWith(first, "loading user", errgo.F("user", 42)) //err annotateWith github.com/hifx/errgo_test.(*fieldsSuite).TestAnnotateWith
+	c.Assert(err.Error(), gc.Equals, "loading user: first")
+	c.Assert(errgo.Cause(err), gc.Equals, first)
+	c.Assert(err.(*errgo.Err).Fields(), jc.DeepEquals, []errgo.Field{{"user", 42}})
+	c.Assert(errgo.Details(err), jc.Contains, replaceLocations("{$annotateWith$: loading user user=42}"))
+
+	c.Assert(errgo.AnnotateWith(nil, "annotate", errgo.F("user", 42)), gc.IsNil)
+}
+
+func (*fieldsSuite) TestTraceWith(c *gc.C) {
+	first := errgo.New("first")
+	err := errgo.TraceWith(first, errgo.F("user", 42), errgo.F("tenant", "acme")) //err traceWith github.com/hifx/errgo_test.(*fieldsSuite).TestTraceWith
+	c.Assert(err.Error(), gc.Equals, "first")
+	c.Assert(errgo.Cause(err), gc.Equals, first)
+	c.Assert(errgo.Details(err), jc.Contains, replaceLocations("{$traceWith$:  user=42 tenant=acme}"))
+
+	c.Assert(errgo.TraceWith(nil, errgo.F("user", 42)), gc.IsNil)
+}
+
+func (*fieldsSuite) TestFields(c *gc.C) {
+	c.Assert(errgo.Fields(nil), gc.IsNil)
+	c.Assert(errgo.Fields(fmt.Errorf("raw")), gc.IsNil)
+
+	err := errgo.AnnotateWith(fmt.Errorf("raw"), "querying", errgo.F("table", "users"), errgo.F("row", 1))
+	err = errgo.Trace(err)
+	err = errgo.Wrap(err, errgo.NotFoundf("user"))
+	err = errgo.AnnotateWith(err, "loading", errgo.F("row", 2), errgo.F("path", "/users/2"))
+	c.Assert(errgo.Fields(err), jc.DeepEquals, []errgo.Field{
+		{"table", "users"},
+		{"row", 2},
+		{"path", "/users/2"},
+	})
+}
+
+func (*fieldsSuite) TestFieldsThroughOtherErrors(c *gc.C) {
+	err := errgo.Join(errgo.AnnotateWith(io.EOF, "x", errgo.F("k", 1)))
+	c.Assert(errgo.Fields(err), jc.DeepEquals, []errgo.Field{{"k", 1}})
+
+	err = fmt.Errorf("wrapped: %w", errgo.AnnotateWith(io.EOF, "x", errgo.F("k", 1)))
+	err = errgo.AnnotateWith(err, "y", errgo.F("user", 42))
+	c.Assert(errgo.Fields(err), jc.DeepEquals, []errgo.Field{{"k", 1}, {"user", 42}})
+
+	// The fields of a cause set by Wrap are included.
+	err = errgo.Wrap(errgo.TraceWith(io.EOF, errgo.F("k", 1)), errgo.TraceWith(errgo.New("cause"), errgo.F("k", 2)))
+	c.Assert(errgo.Fields(err), jc.DeepEquals, []errgo.Field{{"k", 2}})
+}
+
+func (*fieldsSuite) TestErrorStack(c *gc.C) {
+	err := errgo.New("first")                                                  //err fields-0 (*fieldsSuite).TestErrorStack
+	err = errgo.AnnotateWith(err, "loading", errgo.F("user", 42))              //err fields-1 (*fieldsSuite).TestErrorStack
+	err = errgo.Wrap(err, fmt.Errorf("detailed"))                              //err fields-2 (*fieldsSuite).TestErrorStack
+	err = errgo.TraceWith(err, errgo.F("path", "/users/42"), errgo.F("x", "")) //err fields-3 (*fieldsSuite).TestErrorStack
+	c.Assert(errgo.ErrorStack(err), gc.Equals, replaceLocations(""+
+		"$fields-0$: first\n"+
+		"$fields-1$: loading user=42\n"+
+		"$fields-2$: detailed\n"+
+		`$fields-3$:  path=/users/42 x=""`))
+}
+
+func (*fieldsSuite) TestJSON(c *gc.C) {
+	err := errgo.AnnotateWith(errgo.New("first"), "loading", errgo.F("user", 42), errgo.F("path", "/users/42"))
+	data, jsonErr := json.Marshal(err)
+	c.Assert(jsonErr, gc.IsNil)
+	c.Assert(string(data), jc.Contains, `"fields":[{"key":"user","value":42},{"key":"path","value":"/users/42"}]`)
+
+	var obtained errgo.Err
+	c.Assert(json.Unmarshal(data, &obtained), gc.IsNil)
+	c.Assert(errgo.Fields(&obtained), jc.DeepEquals, []errgo.Field{{"user", float64(42)}, {"path", "/users/42"}})
+	c.Assert(errgo.ErrorStack(&obtained), gc.Equals, errgo.ErrorStack(err))
+}
+
+// node is a linked list node, which may form a cycle.
+type node struct {
+	Next *node
+}
+
+func (*fieldsSuite) TestJSONUnsupportedValues(c *gc.C) {
+	cycle := &node{}
+	cycle.Next = cycle
+	ch := make(chan int)
+	err := errgo.AnnotateWith(errgo.New("first"), "loading",
+		errgo.F("chan", ch),
+		errgo.F("func", func() {}),
+		errgo.F("cycle", cycle),
+		errgo.F("user", 42),
+	)
+	data, jsonErr := json.Marshal(err)
+	c.Assert(jsonErr, gc.IsNil)
+
+	var obtained errgo.Err
+	c.Assert(json.Unmarshal(data, &obtained), gc.IsNil)
+	fields := errgo.Fields(&obtained)
+	c.Assert(fields, gc.HasLen, 4)
+	c.Assert(fields[0], gc.Equals, errgo.F("chan", fmt.Sprint(ch)))
+	c.Assert(fields[1].Key, gc.Equals, "func")
+	c.Assert(fields[1].Value, gc.FitsTypeOf, "")
+	c.Assert(fields[2], gc.Equals, errgo.F("cycle", fmt.Sprint(cycle)))
+	c.Assert(fields[3], gc.Equals, errgo.F("user", float64(42)))
+}
@@ -0,0 +1,118 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MessageFormat returns the format string the message of this entry in the
+// error stack was created from, such as by Errorf or Annotatef, or the
+// message itself if it was not formatted.
+func (e *Err) MessageFormat() string {
+	if e.format != "" {
+		return e.format
+	}
+	return e.message
+}
+
+type messageFormatter interface {
+	MessageFormat() string
+}
+
+var _ messageFormatter = (*Err)(nil)
+
+// Fingerprint returns a short hash identifying the errors which are
+// occurrences of the same error, so that they can be grouped and
+// deduplicated, or the empty string if err is nil. Errors have the same
+// fingerprint if their Causes have the same type and message template, and
+// their error stacks have the same origin.
+//
+// The message template is the format string the message was created from,
+// so that errors which differ only in their formatting arguments share a
+// fingerprint. Messages which were not formatted are used as they are.
+// Causes from outside this package, whose messages may well hold such
+// arguments, are identified by their type alone. The origin is the file,
+// function and line of the first entry in the error stack, as shown by
+// ErrorStack, which has a location.
+//
+// For example, all the errors returned by
+//     func load(id int) error {
+//         return errgo.NotFoundf("user %d", id)
+//     }
+// have the same fingerprint, however they are annotated.
+func Fingerprint(err error) string {
+	return fingerprint(err, true)
+}
+
+// FingerprintIgnoringLines is like Fingerprint, but ignores the line of the
+// origin, so that fingerprints are kept when code is moved within a
+// function.
+func FingerprintIgnoringLines(err error) string {
+	return fingerprint(err, false)
+}
+
+func fingerprint(err error, withLine bool) string {
+	if err == nil {
+		return ""
+	}
+	cause := Cause(err)
+	origin := originOf(err)
+	template := messageTemplate(cause)
+	if template == "" && origin != nil {
+		template = messageTemplate(origin)
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%T\n%s\n", cause, normalizeFormat(template))
+	if origin, ok := origin.(locationer); ok {
+		file, function, line := origin.Location()
+		fmt.Fprintf(h, "%s\n%s\n", file, function)
+		if withLine {
+			fmt.Fprintf(h, "%d\n", line)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// originOf returns the first error in the stack of err which has a
+// location, or nil if there is none.
+func originOf(err error) error {
+	var origin error
+	for ; err != nil; err = underlying(err) {
+		if e, ok := err.(locationer); ok {
+			if file, _, _ := e.Location(); file != "" {
+				origin = err
+			}
+		}
+	}
+	return origin
+}
+
+// messageTemplate returns the template of the message of err, as used by
+// Fingerprint, or the empty string for errors from outside this package.
+func messageTemplate(err error) string {
+	switch err := err.(type) {
+	case messageFormatter:
+		return err.MessageFormat()
+	case wrapper:
+		return err.Message()
+	}
+	return ""
+}
+
+// formatVerb matches the verbs of a format string, with their flags,
+// argument indexes, widths and precisions.
+var formatVerb = regexp.MustCompile(`%[-+# 0]*(\[\d+\])?(\*|\d+)?(\.(\*|\d+)?)?[a-zA-Z]`)
+
+// normalizeFormat returns format with every verb written as %v and runs of
+// white space collapsed, so that changes to the formatting of arguments do
+// not change fingerprints.
+func normalizeFormat(format string) string {
+	format = formatVerb.ReplaceAllString(format, "%v")
+	return strings.Join(strings.Fields(format), " ")
+}
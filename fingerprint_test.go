@@ -0,0 +1,109 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	gc "gopkg.in/check.v1"
+
+	"github.com/hifx/errgo"
+)
+
+type fingerprintSuite struct{}
+
+var _ = gc.Suite(&fingerprintSuite{})
+
+// loadUser returns a not found error created at a single location.
+func loadUser(id int) error {
+	return errgo.NotFoundf("user %d", id)
+}
+
+type customError struct{}
+
+func (*customError) Error() string { return "custom" }
+
+func (*fingerprintSuite) TestMessageFormat(c *gc.C) {
+	err := errgo.Errorf("user %d", 42).(*errgo.Err)
+	c.Assert(err.MessageFormat(), gc.Equals, "user %d")
+	err = errgo.New("no rows").(*errgo.Err)
+	c.Assert(err.MessageFormat(), gc.Equals, "no rows")
+	err = errgo.Annotatef(err, "loading %s", "user").(*errgo.Err)
+	c.Assert(err.MessageFormat(), gc.Equals, "loading %s")
+}
+
+func (*fingerprintSuite) TestFingerprint(c *gc.C) {
+	first := loadUser(1)
+	c.Assert(errgo.Fingerprint(first), gc.HasLen, 16)
+	c.Assert(errgo.Fingerprint(nil), gc.Equals, "")
+
+	// Arguments and annotations do not change the fingerprint.
+	c.Assert(errgo.Fingerprint(loadUser(2)), gc.Equals, errgo.Fingerprint(first))
+	c.Assert(errgo.Fingerprint(errgo.Annotatef(loadUser(3), "request %d", 7)), gc.Equals, errgo.Fingerprint(first))
+	c.Assert(errgo.Fingerprint(errgo.Trace(errgo.Mask(loadUser(4)))), gc.Equals, errgo.Fingerprint(first))
+
+	// The origin does.
+	c.Assert(errgo.Fingerprint(errgo.NotFoundf("user %d", 1)), gc.Not(gc.Equals), errgo.Fingerprint(first))
+
+	// So do the type and message template of the cause.
+	var errs []error
+	for _, cause := range []error{nil, io.EOF, &customError{}, errgo.New("other")} {
+		err := loadUser(1)
+		if cause != nil {
+			err = errgo.Wrap(err, cause)
+		}
+		errs = append(errs, err)
+	}
+	for i := range errs {
+		for j := range errs {
+			c.Check(errgo.Fingerprint(errs[i]) == errgo.Fingerprint(errs[j]), gc.Equals, i == j, gc.Commentf("%d, %d", i, j))
+		}
+	}
+}
+
+func (*fingerprintSuite) TestFingerprintForeignCause(c *gc.C) {
+	// Causes from outside errgo are identified by their type, not their
+	// messages, which may hold arguments.
+	var errs []error
+	for _, address := range []string{"10.0.0.1", "10.0.0.2"} {
+		errs = append(errs, errgo.Annotate(fmt.Errorf("dial %s: refused", address), "connecting"))
+	}
+	c.Assert(errgo.Fingerprint(errs[0]), gc.Equals, errgo.Fingerprint(errs[1]))
+	c.Assert(errgo.Fingerprint(errs[0]), gc.Not(gc.Equals), errgo.Fingerprint(errgo.Annotate(&customError{}, "connecting")))
+}
+
+func (*fingerprintSuite) TestFingerprintMessageTemplate(c *gc.C) {
+	var errs []error
+	for _, format := range []string{"user %d", "user %v", "user  %5d ", "account %d"} {
+		errs = append(errs, errgo.Errorf(format, 42))
+	}
+	fingerprints := make(map[string]bool)
+	for _, err := range errs {
+		fingerprints[errgo.Fingerprint(err)] = true
+	}
+	c.Assert(fingerprints, gc.HasLen, 2)
+	c.Assert(errgo.Fingerprint(errs[0]), gc.Equals, errgo.Fingerprint(errs[2]))
+	c.Assert(errgo.Fingerprint(errs[0]), gc.Not(gc.Equals), errgo.Fingerprint(errs[3]))
+}
+
+func (*fingerprintSuite) TestFingerprintIgnoringLines(c *gc.C) {
+	first := errgo.Errorf("user %d", 1)
+	second := errgo.Errorf("user %d", 2)
+	c.Assert(errgo.Fingerprint(first), gc.Not(gc.Equals), errgo.Fingerprint(second))
+	c.Assert(errgo.FingerprintIgnoringLines(first), gc.Equals, errgo.FingerprintIgnoringLines(second))
+	c.Assert(errgo.FingerprintIgnoringLines(first), gc.Not(gc.Equals), errgo.FingerprintIgnoringLines(loadUser(1)))
+	c.Assert(errgo.FingerprintIgnoringLines(nil), gc.Equals, "")
+}
+
+func (*fingerprintSuite) TestFingerprintJSON(c *gc.C) {
+	err := errgo.Annotate(loadUser(1), "loading")
+	data, jsonErr := json.Marshal(err)
+	c.Assert(jsonErr, gc.IsNil)
+	var decoded errgo.Err
+	c.Assert(json.Unmarshal(data, &decoded), gc.IsNil)
+	c.Assert(errgo.Fingerprint(&decoded), gc.Equals, errgo.Fingerprint(err))
+	c.Assert(decoded.Underlying().(*errgo.Err).MessageFormat(), gc.Equals, "user %d")
+}
@@ -0,0 +1,147 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format implements fmt.Formatter, as for FormatError. Types embedding Err
+// define their own, as shown for NewErr.
+//
+// For example:
+//     fmt.Printf("%+v\n", err)
+func (e *Err) Format(s fmt.State, verb rune) {
+	FormatError(s, verb, e)
+}
+
+// Format implements fmt.Formatter, as for FormatError.
+func (m *MultiError) Format(s fmt.State, verb rune) {
+	FormatError(s, verb, m)
+}
+
+var (
+	_ fmt.Formatter = (*Err)(nil)
+	_ fmt.Formatter = (*MultiError)(nil)
+)
+
+// FormatError formats err for fmt.Formatter implementations. The %s and
+// %v verbs write the result of Error, %q writes it quoted, and %x and %X
+// write it in hexadecimal, as fmt does for any error. The %+v verb writes
+// the lines of ErrorStack, with functions named by their full import path,
+// and %#v writes a Go-syntax representation of the error stack for
+// debugging.
+//
+// For example:
+//     func (e *FooError) Format(s fmt.State, verb rune) {
+//         errgo.FormatError(s, verb, e)
+//     }
+func FormatError(s fmt.State, verb rune, err error) {
+	switch verb {
+	case 'v':
+		switch {
+		case s.Flag('+'):
+			io.WriteString(s, strings.Join(stackLines(err, fullName, false), "\n"))
+		case s.Flag('#'):
+			io.WriteString(s, goSyntax(err))
+		default:
+			io.WriteString(s, err.Error())
+		}
+	case 's':
+		io.WriteString(s, err.Error())
+	case 'q', 'x', 'X':
+		fmt.Fprintf(s, fmt.FormatString(s, verb), err.Error())
+	default:
+		fmt.Fprintf(s, "%%!%c(%T=%s)", verb, err, err.Error())
+	}
+}
+
+// fullName returns the function name unchanged, for stackLines.
+func fullName(function string) string {
+	return function
+}
+
+// goSyntax returns the Go-syntax representation of err written by the %#v
+// verb. Unlike the default representation, the errors in the stack are
+// written out rather than as pointer values. Fields which hold their zero
+// value are omitted, as is the cause when it is simply the cause of the
+// previous error.
+func goSyntax(err error) string {
+	var e *Err
+	var errs []error
+	switch err := err.(type) {
+	case *Err:
+		e = err
+	case *MultiError:
+		e = &err.Err
+		errs = err.errs
+	case interface {
+		error
+		fmt.Formatter
+	}:
+		// The error may format itself with FormatError, which would call
+		// goSyntax again.
+		return fmt.Sprintf("%T(%q)", err, err.Error())
+	default:
+		return fmt.Sprintf("%#v", err)
+	}
+	var members []string
+	add := func(name string, value interface{}) {
+		members = append(members, fmt.Sprintf("%s:%#v", name, value))
+	}
+	if errs != nil {
+		nested := make([]string, len(errs))
+		for i, err := range errs {
+			nested[i] = goSyntax(err)
+		}
+		members = append(members, "errs:[]error{"+strings.Join(nested, ", ")+"}")
+	}
+	if e.message != "" {
+		add("message", e.message)
+	}
+	if e.publicMessage != "" {
+		add("publicMessage", e.publicMessage)
+	}
+	if e.previous != nil {
+		members = append(members, "previous:"+goSyntax(e.previous))
+	}
+	if e.cause != nil && !sameError(Cause(e.previous), e.cause) {
+		members = append(members, "cause:"+goSyntax(e.cause))
+	}
+	if e.masked {
+		add("masked", e.masked)
+	}
+	if e.file != "" {
+		add("file", e.file)
+		add("line", e.line)
+		add("function", e.function)
+	}
+	if e.code != 0 {
+		add("code", e.code)
+	}
+	if e.errorCode != nil {
+		add("errorCode", e.errorCode.Code)
+	}
+	if e.fields != nil {
+		add("fields", e.fields)
+	}
+	if e.templateArgs != nil {
+		add("templateArgs", e.templateArgs)
+	}
+	if e.contextValues != nil {
+		add("contextValues", e.contextValues)
+	}
+	if e.retry != retryUnknown {
+		add("retryable", e.retry == retryRetryable)
+	}
+	if e.retryAfter != 0 {
+		add("retryAfter", e.retryAfter)
+	}
+	if e.contentType != "" {
+		add("contentType", e.contentType)
+	}
+	return fmt.Sprintf("&%s{%s}", strings.TrimPrefix(fmt.Sprintf("%T", err), "*"), strings.Join(members, ", "))
+}
@@ -0,0 +1,97 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo_test
+
+import (
+	"errors"
+	"fmt"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/hifx/errgo"
+)
+
+type formatSuite struct{}
+
+var _ = gc.Suite(&formatSuite{})
+
+func (*formatSuite) TestFormat(c *gc.C) {
+	err := errgo.New("first")                                     //err format-0 github.com/hifx/errgo_test.(*formatSuite).TestFormat
+	err = errgo.AnnotateWith(err, "loading", errgo.F("user", 42)) //err format-1 github.com/hifx/errgo_test.(*formatSuite).TestFormat
+	err = errgo.Wrap(err, errgo.NotFoundf(`user "42"`))           //err format-2 github.com/hifx/errgo_test.(*formatSuite).TestFormat
+	err = errgo.Annotate(err, "handling request")                 //err format-3 github.com/hifx/errgo_test.(*formatSuite).TestFormat
+	c.Assert(fmt.Sprintf("%v", err), gc.Equals, err.Error())
+	c.Assert(fmt.Sprintf("%s", err), gc.Equals, err.Error())
+	c.Assert(fmt.Sprintf("%q", err), gc.Equals, `"handling request: user \"42\""`)
+	c.Assert(fmt.Sprintf("%#q", err), gc.Equals, "`handling request: user \"42\"`")
+	c.Assert(fmt.Sprintf("%+v", err), gc.Equals, replaceLocations(""+
+		"$format-0$: first\n"+
+		"$format-1$: loading user=42\n"+
+		`$format-2$: user "42"`+"\n"+
+		"$format-3$: handling request"))
+	c.Assert(fmt.Sprintf("%x", err), gc.Equals, fmt.Sprintf("%x", err.Error()))
+	c.Assert(fmt.Sprintf("% X", err), gc.Equals, fmt.Sprintf("% X", err.Error()))
+	c.Assert(fmt.Sprintf("%d", err), gc.Equals, `%!d(*errgo.Err=handling request: user "42")`)
+}
+
+func (*formatSuite) TestFormatMultiError(c *gc.C) {
+	err := errgo.Join(errgo.New("first"), errors.New("second")) //err format-multi github.com/hifx/errgo_test.(*formatSuite).TestFormatMultiError
+	c.Assert(fmt.Sprintf("%v", err), gc.Equals, "first; second")
+	c.Assert(fmt.Sprintf("%+v", err), gc.Equals, replaceLocations(""+
+		"$format-multi$: first; second\n"+
+		"\t$format-multi$: first\n"+
+		"\tsecond"))
+	c.Assert(fmt.Sprintf("%#v", err), gc.Equals, fmt.Sprintf(
+		`&errgo.MultiError{errs:[]error{&errgo.Err{message:"first", file:%q, line:%d, function:%q}, &errors.errorString{s:"second"}}, file:%q, line:%d, function:%q}`,
+		location("format-multi").file, location("format-multi").line, "github.com/hifx/errgo_test.(*formatSuite).TestFormatMultiError",
+		location("format-multi").file, location("format-multi").line, "github.com/hifx/errgo_test.(*formatSuite).TestFormatMultiError",
+	))
+}
+
+func (*formatSuite) TestGoSyntax(c *gc.C) {
+	first := errors.New("first")
+	err := errgo.AnnotateWith(first, "loading", errgo.F("user", 42)) //err format-gosyntax
+	err = errgo.Wrap(err, errgo.NotFoundf("user"))                   //err format-gosyntax-wrap
+	loc, wrapLoc := location("format-gosyntax"), location("format-gosyntax-wrap")
+	function := "github.com/hifx/errgo_test.(*formatSuite).TestGoSyntax"
+	c.Assert(fmt.Sprintf("%#v", err), gc.Equals, fmt.Sprintf(""+
+		`&errgo.Err{`+
+		`previous:&errgo.Err{message:"loading", previous:&errors.errorString{s:"first"}, file:%q, line:%d, function:%q, fields:[]errgo.Field{errgo.Field{Key:"user", Value:42}}}, `+
+		`cause:&errgo.Err{message:"user", file:%q, line:%d, function:%q, code:404}, `+
+		`file:%q, line:%d, function:%q}`,
+		loc.file, loc.line, function,
+		wrapLoc.file, wrapLoc.line, function,
+		wrapLoc.file, wrapLoc.line, function,
+	))
+}
+
+// formattedError embeds errgo.Err, defining its own Error method and
+// formatting itself with FormatError.
+type formattedError struct {
+	errgo.Err
+	op string
+}
+
+func (e *formattedError) Error() string {
+	return e.op + ": " + e.Err.Error()
+}
+
+func (e *formattedError) Format(s fmt.State, verb rune) {
+	errgo.FormatError(s, verb, e)
+}
+
+func (*formatSuite) TestFormatEmbedded(c *gc.C) {
+	err := &formattedError{errgo.NewErr(0, "no such user"), "lookup"}
+	err.SetLocation(0) //err format-embedded github.com/hifx/errgo_test.(*formatSuite).TestFormatEmbedded
+	c.Assert(fmt.Sprintf("%v", err), gc.Equals, "lookup: no such user")
+	c.Assert(fmt.Sprintf("%s", err), gc.Equals, "lookup: no such user")
+	c.Assert(fmt.Sprintf("%q", err), gc.Equals, `"lookup: no such user"`)
+	c.Assert(fmt.Sprintf("%+v", err), gc.Equals, replaceLocations("$format-embedded$: no such user"))
+	c.Assert(fmt.Sprintf("%#v", err), gc.Equals, `*errgo_test.formattedError("lookup: no such user")`)
+
+	annotated := errgo.Annotate(err, "handling request")
+	c.Assert(fmt.Sprintf("%v", annotated), gc.Equals, "handling request: lookup: no such user")
+	c.Assert(fmt.Sprintf("%#v", annotated), jc.Contains, `previous:*errgo_test.formattedError("lookup: no such user")`)
+}
@@ -17,6 +17,7 @@ import (
 func New(message string) error {
 	err := &Err{message: message}
 	err.SetLocation(1)
+	err.captureStack(1)
 	return err
 }
 
@@ -27,8 +28,13 @@ func New(message string) error {
 //    return errors.Errorf("validation failed: %s", message)
 //
 func Errorf(format string, args ...interface{}) error {
-	err := &Err{message: fmt.Sprintf(format, args...)}
+	err := &Err{
+		message:     fmt.Sprintf(format, args...),
+		safeMessage: redactf(format, args),
+		format:      format,
+	}
 	err.SetLocation(1)
+	err.captureStack(1)
 	return err
 }
 
@@ -86,9 +92,11 @@ func Annotatef(other error, format string, args ...interface{}) error {
 		return nil
 	}
 	err := &Err{
-		previous: other,
-		cause:    Cause(other),
-		message:  fmt.Sprintf(format, args...),
+		previous:    other,
+		cause:       Cause(other),
+		message:     fmt.Sprintf(format, args...),
+		safeMessage: redactf(format, args),
+		format:      format,
 	}
 	err.SetLocation(1)
 	return err
@@ -108,9 +116,11 @@ func DeferredAnnotatef(err *error, format string, args ...interface{}) {
 		return
 	}
 	newErr := &Err{
-		message:  fmt.Sprintf(format, args...),
-		cause:    Cause(*err),
-		previous: *err,
+		message:     fmt.Sprintf(format, args...),
+		safeMessage: redactf(format, args),
+		format:      format,
+		cause:       Cause(*err),
+		previous:    *err,
 	}
 	newErr.SetLocation(1)
 	*err = newErr
@@ -144,9 +154,11 @@ func Wrap(other, newDescriptive error) error {
 //
 func Wrapf(other, newDescriptive error, format string, args ...interface{}) error {
 	err := &Err{
-		message:  fmt.Sprintf(format, args...),
-		previous: other,
-		cause:    newDescriptive,
+		message:     fmt.Sprintf(format, args...),
+		safeMessage: redactf(format, args),
+		format:      format,
+		previous:    other,
+		cause:       newDescriptive,
 	}
 	err.SetLocation(1)
 	return err
@@ -161,8 +173,11 @@ func Maskf(other error, format string, args ...interface{}) error {
 		return nil
 	}
 	err := &Err{
-		message:  fmt.Sprintf(format, args...),
-		previous: other,
+		message:     fmt.Sprintf(format, args...),
+		safeMessage: redactf(format, args),
+		format:      format,
+		previous:    other,
+		masked:      true,
 	}
 	err.SetLocation(1)
 	return err
@@ -175,6 +190,7 @@ func Mask(other error) error {
 	}
 	err := &Err{
 		previous: other,
+		masked:   true,
 	}
 	err.SetLocation(1)
 	return err
@@ -196,6 +212,52 @@ func Cause(err error) error {
 	return err
 }
 
+// findError returns the most recent error in the stack of err for which
+// match returns true, or nil. The stack is searched through Underlying or
+// the standard Unwrap methods. An error passed to Wrap is searched before
+// the error it replaces, and Mask does not hide the errors beneath it.
+func findError(err error, match func(error) bool) error {
+	if err == nil {
+		return nil
+	}
+	if match(err) {
+		return err
+	}
+	switch e := err.(type) {
+	case interface{ Unwrap() []error }:
+		// This comes first as a MultiError is also a wrapper, through
+		// its embedded Err, but has no previous error.
+		for _, err := range e.Unwrap() {
+			if found := findError(err, match); found != nil {
+				return found
+			}
+		}
+	case wrapper:
+		previous := e.Underlying()
+		if err, ok := err.(causer); ok {
+			// A cause which replaced the previous error is searched first.
+			if cause := err.Cause(); cause != nil && !sameError(Cause(previous), cause) {
+				if found := findError(cause, match); found != nil {
+					return found
+				}
+			}
+		}
+		return findError(previous, match)
+	case interface{ Unwrap() error }:
+		return findError(e.Unwrap(), match)
+	}
+	return nil
+}
+
+// visitErrors calls visit with every error in the stack of err, most
+// recent first, in the order in which findError searches them.
+func visitErrors(err error, visit func(error)) {
+	findError(err, func(e error) bool {
+		visit(e)
+		return false
+	})
+}
+
 type causer interface {
 	Cause() error
 }
@@ -211,6 +273,10 @@ type wrapper interface {
 	Underlying() error
 }
 
+type unwrapper interface {
+	Unwrap() error
+}
+
 type locationer interface {
 	Location() (string, string, int)
 }
@@ -219,12 +285,16 @@ var (
 	_ wrapper    = (*Err)(nil)
 	_ locationer = (*Err)(nil)
 	_ causer     = (*Err)(nil)
+	_ unwrapper  = (*Err)(nil)
 )
 
 // Details returns information about the stack of errors wrapped by err, in
 // the format:
 //
-// 	[{filename:99: error one} {otherfile:55: cause of error one}]
+// 	[{filename:99: error one} {otherfile:55: cause of error one user=42}]
+//
+// where any fields attached to an entry follow its message as key=value
+// pairs.
 //
 // This is a terse alternative to ErrorStack as it returns a single line.
 func Details(err error) string {
@@ -244,6 +314,7 @@ func Details(err error) string {
 		}
 		if cerr, ok := err.(wrapper); ok {
 			s = append(s, cerr.Message()...)
+			s = appendFields(s, err, false)
 			err = cerr.Underlying()
 		} else {
 			s = append(s, err.Error()...)
@@ -264,8 +335,13 @@ func Details(err error) string {
 // simply the result of the Error() method on that error.
 //
 // If the error is an annotated error, a multi-line string is returned where
-// each line represents one entry in the annotation stack. The full filename
-// from the call stack is used in the output.
+// each line represents one entry in the annotation stack. Files are named by
+// the import path of their package, whatever the build mode. If stack capture
+// was enabled when an error was created (see SetStackCapture), the captured
+// call stack follows its entry, one tab indented line per frame. The error
+// stacks of the errors held by a MultiError follow its entry in the same
+// way. Formatting an *Err with the %+v verb gives the same lines, with
+// functions named in full.
 //
 //     first error
 //     github.com/hifx/errgo/annotation_test.go:193:
@@ -278,19 +354,50 @@ func ErrorStack(err error) string {
 }
 
 func errorStack(err error) []string {
+	return stackLines(err, trimPackage, false)
+}
+
+// errorText returns the message of err, as returned by SafeError if safe
+// is true, or by its Error method otherwise.
+func errorText(err error, safe bool) string {
+	if safe {
+		return SafeError(err)
+	}
+	return err.Error()
+}
+
+// stackLines returns the lines of the error stack of err, as for
+// ErrorStack, with function names written by funcName. If safe is true,
+// sensitive data is redacted, as for SafeErrorStack.
+func stackLines(err error, funcName func(string) string, safe bool) []string {
 	if err == nil {
 		return nil
 	}
 
 	// We want the first error first
-	var lines []string
+	var entries [][]string
 	for {
 		var buff []byte
+		// Any captured call stack, and the stacks of any errors held by a
+		// MultiError, are shown indented below the entry.
+		var frames []string
+		if err, ok := err.(stackFramer); ok {
+			for _, frame := range err.StackFrames() {
+				frames = append(frames, fmt.Sprintf("\t%s:%d %s", frame.File, frame.Line, funcName(frame.Function)))
+			}
+		}
+		if err, ok := err.(multiError); ok {
+			for _, err := range err.Errors() {
+				for _, line := range stackLines(err, funcName, safe) {
+					frames = append(frames, "\t"+line)
+				}
+			}
+		}
 		if err, ok := err.(locationer); ok {
 			file, function, line := err.Location()
-			// Strip off the leading GOPATH/src path elements.
-			file = trimGoPath(file)
-			function = trimPackage(function)
+			// Strip off the build specific leading path elements.
+			file = trimSourcePath(file, function)
+			function = funcName(function)
 			if file != "" {
 				buff = append(buff, fmt.Sprintf("%s:%d %s", file, line, function)...)
 				buff = append(buff, ": "...)
@@ -298,9 +405,13 @@ func errorStack(err error) []string {
 		}
 		if cerr, ok := err.(wrapper); ok {
 			message := cerr.Message()
+			if safe {
+				message = safeMessage(err)
+			}
 			buff = append(buff, message...)
 			// If there is a cause for this error, and it is different to the cause
 			// of the underlying error, then output the error string in the stack trace.
+			buff = appendFields(buff, err, safe)
 			var cause error
 			if err1, ok := err.(causer); ok {
 				cause = err1.Cause()
@@ -310,22 +421,22 @@ func errorStack(err error) []string {
 				if message != "" {
 					buff = append(buff, ": "...)
 				}
-				buff = append(buff, cause.Error()...)
+				buff = append(buff, errorText(cause, safe)...)
 			}
 		} else {
-			buff = append(buff, err.Error()...)
+			buff = append(buff, errorText(err, safe)...)
 			err = nil
 		}
-		lines = append(lines, string(buff))
+		entries = append(entries, append([]string{string(buff)}, frames...))
 		if err == nil {
 			break
 		}
 	}
-	// reverse the lines to get the original error, which was at the end of
+	// reverse the entries to get the original error, which was at the end of
 	// the list, back to the start.
 	var result []string
-	for i := len(lines); i > 0; i-- {
-		result = append(result, lines[i-1])
+	for i := len(entries); i > 0; i-- {
+		result = append(result, entries[i-1]...)
 	}
 	return result
 }
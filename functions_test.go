@@ -4,7 +4,9 @@
 package errgo_test
 
 import (
+	stderrors "errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -303,3 +305,150 @@ func (*functionSuite) TestErrorStack(c *gc.C) {
 		}
 	}
 }
+
+func (*functionSuite) TestUnwrap(c *gc.C) {
+	deferred := func(err error) (result error) {
+		defer errgo.DeferredAnnotatef(&result, "deferred")
+		return err
+	}
+	detailed := newError("detailed")
+	for i, test := range []struct {
+		message string
+		err     error
+		is      error
+		isNot   error
+		unwraps bool
+	}{{
+		message: "New",
+		err:     errgo.New("first"),
+		isNot:   io.EOF,
+	}, {
+		message: "Errorf",
+		err:     errgo.Errorf("first %d", 1),
+		isNot:   io.EOF,
+	}, {
+		message: "Trace",
+		err:     errgo.Trace(io.EOF),
+		is:      io.EOF,
+		unwraps: true,
+	}, {
+		message: "Annotate",
+		err:     errgo.Annotate(io.EOF, "annotation"),
+		is:      io.EOF,
+		unwraps: true,
+	}, {
+		message: "Annotatef",
+		err:     errgo.Annotatef(io.EOF, "annotation %d", 2),
+		is:      io.EOF,
+		unwraps: true,
+	}, {
+		message: "DeferredAnnotatef",
+		err:     deferred(io.EOF),
+		is:      io.EOF,
+		unwraps: true,
+	}, {
+		message: "Wrap",
+		err:     errgo.Wrap(io.EOF, detailed),
+		is:      detailed,
+		isNot:   io.EOF,
+		unwraps: true,
+	}, {
+		message: "Wrapf",
+		err:     errgo.Wrapf(io.EOF, detailed, "value %d", 42),
+		is:      detailed,
+		isNot:   io.EOF,
+		unwraps: true,
+	}, {
+		message: "Wrap of nil",
+		err:     errgo.Wrap(nil, detailed),
+		is:      detailed,
+		unwraps: true,
+	}, {
+		message: "Mask",
+		err:     errgo.Mask(io.EOF),
+		isNot:   io.EOF,
+	}, {
+		message: "Maskf",
+		err:     errgo.Maskf(io.EOF, "masked"),
+		isNot:   io.EOF,
+	}, {
+		message: "annotated mask",
+		err:     errgo.Annotate(errgo.Maskf(io.EOF, "masked"), "annotation"),
+		isNot:   io.EOF,
+		unwraps: true,
+	}, {
+		message: "traced wrap of an annotation",
+		err:     errgo.Trace(errgo.Wrap(errgo.Annotate(io.EOF, "annotation"), detailed)),
+		is:      detailed,
+		isNot:   io.EOF,
+		unwraps: true,
+	}} {
+		c.Logf("%v: %s", i, test.message)
+		c.Check(stderrors.Is(test.err, test.err), jc.IsTrue)
+		if test.is != nil {
+			c.Check(stderrors.Is(test.err, test.is), jc.IsTrue)
+		}
+		if test.isNot != nil {
+			c.Check(stderrors.Is(test.err, test.isNot), jc.IsFalse)
+		}
+		c.Check(stderrors.Unwrap(test.err) != nil, gc.Equals, test.unwraps)
+
+		var target *errgo.Err
+		c.Check(stderrors.As(test.err, &target), jc.IsTrue)
+		c.Check(target, gc.Equals, test.err)
+	}
+}
+
+func (*functionSuite) TestAsThroughAnnotations(c *gc.C) {
+	first := newEmbed("embedded")
+	err := errgo.Annotate(errgo.Trace(first), "annotation")
+
+	var target *embed
+	c.Assert(stderrors.As(err, &target), jc.IsTrue)
+	c.Assert(target, gc.Equals, first)
+
+	var pathErr *os.PathError
+	c.Assert(stderrors.As(err, &pathErr), jc.IsFalse)
+
+	_, statErr := os.Stat(filepath.Join(c.MkDir(), "not-there"))
+	err = errgo.Annotate(statErr, "annotation")
+	c.Assert(stderrors.As(err, &pathErr), jc.IsTrue)
+	c.Assert(stderrors.Is(err, os.ErrNotExist), jc.IsTrue)
+
+	pathErr = nil
+	c.Assert(stderrors.As(errgo.Mask(statErr), &pathErr), jc.IsFalse)
+	c.Assert(stderrors.Is(errgo.Mask(statErr), os.ErrNotExist), jc.IsFalse)
+}
+
+func newNestedError() error {
+	return errgo.New("nested") //err stackFrames-0 newNestedError
+}
+
+func (*functionSuite) TestStackFrames(c *gc.C) {
+	if runtime.Compiler == "gccgo" {
+		c.Skip("gccgo can't determine the location")
+	}
+	defer errgo.SetStackCapture(errgo.StackCapture())
+
+	errgo.SetStackCapture(false)
+	err := newNestedError()
+	c.Assert(err.(*errgo.Err).StackFrames(), gc.IsNil)
+	c.Assert(errgo.ErrorStack(err), gc.Equals, replaceLocations("$stackFrames-0$: nested"))
+
+	errgo.SetStackCapture(true)
+	err = newNestedError() //err stackFrames-1 (*functionSuite).TestStackFrames
+	frames := err.(*errgo.Err).StackFrames()
+	c.Assert(len(frames) > 2, jc.IsTrue)
+	c.Assert(frames[0].String(), gc.Equals, location("stackFrames-0").String())
+	c.Assert(frames[1].String(), gc.Equals, location("stackFrames-1").String())
+	c.Assert(frames[1].Function, gc.Equals, "github.com/hifx/errgo_test.(*functionSuite).TestStackFrames")
+
+	err = errgo.Annotate(err, "annotation") //err stackFrames-2 (*functionSuite).TestStackFrames
+	lines := strings.Split(errgo.ErrorStack(err), "\n")
+	c.Assert(lines, gc.HasLen, len(frames)+2)
+	c.Assert(lines[0], gc.Equals, replaceLocations("$stackFrames-0$: nested"))
+	c.Assert(lines[1], gc.Equals, "\t"+location("stackFrames-0").String())
+	c.Assert(lines[2], gc.Equals, "\t"+location("stackFrames-1").String())
+	c.Assert(lines[len(lines)-1], gc.Equals, replaceLocations("$stackFrames-2$: annotation"))
+	c.Assert(err.(*errgo.Err).StackTrace(), gc.DeepEquals, lines)
+}
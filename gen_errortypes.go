@@ -0,0 +1,122 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+//go:build ignore
+
+// This program generates errortypes_generated.go from the table of HTTP
+// error kinds below. Run it with go generate after changing the table.
+package main
+
+import (
+	"bytes"
+	"go/format"
+	"log"
+	"os"
+	"text/template"
+)
+
+// kind describes one HTTP error kind.
+type kind struct {
+	// Name is used to name the Xf, NewX and IsX functions.
+	Name string
+
+	// Status is the name of the net/http status code constant.
+	Status string
+
+	// Doc completes the sentence "Name represents an error when".
+	Doc string
+}
+
+var kinds = []kind{
+	{"BadRequest", "StatusBadRequest", "a request has bad parameters."},
+	{"Unauthorized", "StatusUnauthorized", "an operation is unauthorized."},
+	{"PaymentRequired", "StatusPaymentRequired", "payment is required before the\nrequest can be processed."},
+	{"Forbidden", "StatusForbidden", "an operation is forbidden to an\nauthenticated client."},
+	{"NotFound", "StatusNotFound", "something has not been found."},
+	{"MethodNotAllowed", "StatusMethodNotAllowed", "an HTTP request\nis made with an inappropriate method."},
+	{"NotAcceptable", "StatusNotAcceptable", "no representation acceptable to the\nclient is available."},
+	{"RequestTimeout", "StatusRequestTimeout", "the client did not complete its\nrequest in time."},
+	{"Conflict", "StatusConflict", "a request conflicts with the current\nstate of a resource."},
+	{"Gone", "StatusGone", "a resource is permanently gone."},
+	{"LengthRequired", "StatusLengthRequired", "a request is missing its content\nlength."},
+	{"PreconditionFailed", "StatusPreconditionFailed", "a request precondition\ndoes not hold."},
+	{"PayloadTooLarge", "StatusRequestEntityTooLarge", "a request body is too large."},
+	{"URITooLong", "StatusRequestURITooLong", "a request URI is too long."},
+	{"UnsupportedMediaType", "StatusUnsupportedMediaType", "a request body has an\nunsupported media type."},
+	{"RangeNotSatisfiable", "StatusRequestedRangeNotSatisfiable", "a requested range\ncannot be satisfied."},
+	{"ExpectationFailed", "StatusExpectationFailed", "the Expect header of a\nrequest cannot be met."},
+	{"MisdirectedRequest", "StatusMisdirectedRequest", "a request was sent to a\nserver unable to respond to it."},
+	{"UnprocessableEntity", "StatusUnprocessableEntity", "a well formed request\ncannot be processed because of its content."},
+	{"Locked", "StatusLocked", "a resource is locked."},
+	{"FailedDependency", "StatusFailedDependency", "a request failed because an\nearlier request failed."},
+	{"TooEarly", "StatusTooEarly", "a request might be replayed."},
+	{"UpgradeRequired", "StatusUpgradeRequired", "the client must switch to a\ndifferent protocol."},
+	{"PreconditionRequired", "StatusPreconditionRequired", "a request must be\nconditional."},
+	{"TooManyRequests", "StatusTooManyRequests", "a client has sent too many\nrequests."},
+	{"RequestHeaderFieldsTooLarge", "StatusRequestHeaderFieldsTooLarge", "the\nrequest header fields are too large."},
+	{"UnavailableForLegalReasons", "StatusUnavailableForLegalReasons", "a\nresource cannot be provided for legal reasons."},
+	{"InternalServer", "StatusInternalServerError", "something unexpected has happened."},
+	{"NotImplemented", "StatusNotImplemented", "something is not\nimplemented."},
+	{"BadGateway", "StatusBadGateway", "an upstream server returned an\ninvalid response."},
+	{"ServiceUnavailable", "StatusServiceUnavailable", "a service is temporarily\nunavailable."},
+	{"GatewayTimeout", "StatusGatewayTimeout", "an upstream server did not\nrespond in time."},
+	{"HTTPVersionNotSupported", "StatusHTTPVersionNotSupported", "the HTTP\nversion of a request is not supported."},
+	{"InsufficientStorage", "StatusInsufficientStorage", "there is not enough\nstorage to complete a request."},
+	{"LoopDetected", "StatusLoopDetected", "an infinite loop was detected\nwhile processing a request."},
+	{"NotExtended", "StatusNotExtended", "further extensions to a request\nare required."},
+	{"NetworkAuthenticationRequired", "StatusNetworkAuthenticationRequired", "the\nclient needs to authenticate to gain network access."},
+}
+
+var tmpl = template.Must(template.New("").Funcs(template.FuncMap{
+	"comment": comment,
+}).Parse(`// Code generated by gen_errortypes.go; DO NOT EDIT.
+
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo
+
+import (
+	"net/http"
+)
+{{range .}}
+{{comment (printf "%s represents an error when %s" .Name .Doc)}}
+
+// {{.Name}}f returns an error which satisfies Is{{.Name}}().
+func {{.Name}}f(format string, args ...interface{}) error {
+	e := wrap(nil, http.{{.Status}}, format, "", args...)
+	return &e
+}
+
+// New{{.Name}} returns an error which wraps err that satisfies
+// Is{{.Name}}().
+func New{{.Name}}(err error, msg string) error {
+	e := wrap(err, http.{{.Status}}, msg, "")
+	return &e
+}
+
+// Is{{.Name}} reports whether err was created with {{.Name}}f() or
+// New{{.Name}}(), or otherwise has their HTTP code as returned by Code.
+func Is{{.Name}}(err error) bool {
+	return hasCode(err, http.{{.Status}})
+}
+{{end}}`))
+
+// comment returns s as a line comment.
+func comment(s string) string {
+	return "// " + string(bytes.Replace([]byte(s), []byte("\n"), []byte("\n// "), -1))
+}
+
+func main() {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, kinds); err != nil {
+		log.Fatal(err)
+	}
+	data, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("errortypes_generated.go", data, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
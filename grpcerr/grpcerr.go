@@ -0,0 +1,316 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+// Package grpcerr converts errgo errors to and from gRPC statuses, so that
+// the same errors can be returned over gRPC and HTTP.
+package grpcerr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+
+	"github.com/hifx/errgo"
+)
+
+// Domain is the domain of the ErrorInfo details added to statuses.
+const Domain = "github.com/hifx/errgo"
+
+// httpStatusKey is the ErrorInfo metadata key holding the HTTP response
+// code of the error.
+const httpStatusKey = "httpStatus"
+
+// codeForHTTP maps HTTP response codes to gRPC codes.
+var codeForHTTP = map[int]codes.Code{
+	http.StatusBadRequest:                    codes.InvalidArgument,
+	http.StatusUnauthorized:                  codes.Unauthenticated,
+	http.StatusPaymentRequired:               codes.FailedPrecondition,
+	http.StatusForbidden:                     codes.PermissionDenied,
+	http.StatusNotFound:                      codes.NotFound,
+	http.StatusMethodNotAllowed:              codes.Unimplemented,
+	http.StatusNotAcceptable:                 codes.InvalidArgument,
+	http.StatusRequestTimeout:                codes.DeadlineExceeded,
+	http.StatusConflict:                      codes.AlreadyExists,
+	http.StatusGone:                          codes.NotFound,
+	http.StatusLengthRequired:                codes.InvalidArgument,
+	http.StatusPreconditionFailed:            codes.FailedPrecondition,
+	http.StatusRequestEntityTooLarge:         codes.ResourceExhausted,
+	http.StatusRequestURITooLong:             codes.InvalidArgument,
+	http.StatusUnsupportedMediaType:          codes.InvalidArgument,
+	http.StatusRequestedRangeNotSatisfiable:  codes.OutOfRange,
+	http.StatusExpectationFailed:             codes.FailedPrecondition,
+	http.StatusMisdirectedRequest:            codes.Unavailable,
+	http.StatusUnprocessableEntity:           codes.InvalidArgument,
+	http.StatusLocked:                        codes.FailedPrecondition,
+	http.StatusFailedDependency:              codes.FailedPrecondition,
+	http.StatusTooEarly:                      codes.Unavailable,
+	http.StatusUpgradeRequired:               codes.FailedPrecondition,
+	http.StatusPreconditionRequired:          codes.FailedPrecondition,
+	http.StatusTooManyRequests:               codes.ResourceExhausted,
+	http.StatusRequestHeaderFieldsTooLarge:   codes.InvalidArgument,
+	http.StatusUnavailableForLegalReasons:    codes.PermissionDenied,
+	499:                                      codes.Canceled,
+	http.StatusInternalServerError:           codes.Internal,
+	http.StatusNotImplemented:                codes.Unimplemented,
+	http.StatusBadGateway:                    codes.Unavailable,
+	http.StatusServiceUnavailable:            codes.Unavailable,
+	http.StatusGatewayTimeout:                codes.DeadlineExceeded,
+	http.StatusHTTPVersionNotSupported:       codes.Unimplemented,
+	http.StatusInsufficientStorage:           codes.ResourceExhausted,
+	http.StatusLoopDetected:                  codes.Internal,
+	http.StatusNotExtended:                   codes.Unimplemented,
+	http.StatusNetworkAuthenticationRequired: codes.Unauthenticated,
+}
+
+// httpForCode maps gRPC codes to HTTP response codes.
+var httpForCode = map[codes.Code]int{
+	codes.OK:                 http.StatusOK,
+	codes.Canceled:           499,
+	codes.Unknown:            http.StatusInternalServerError,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.FailedPrecondition: http.StatusPreconditionFailed,
+	codes.Aborted:            http.StatusConflict,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Internal:           http.StatusInternalServerError,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DataLoss:           http.StatusInternalServerError,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+}
+
+// CodeFromHTTP returns the gRPC code corresponding to the given HTTP
+// response code. Codes without a closer match are mapped to
+// FailedPrecondition for client errors, Internal for server errors and
+// Unknown otherwise.
+func CodeFromHTTP(status int) codes.Code {
+	if code, ok := codeForHTTP[status]; ok {
+		return code
+	}
+	switch {
+	case status >= 400 && status < 500:
+		return codes.FailedPrecondition
+	case status >= 500 && status < 600:
+		return codes.Internal
+	}
+	return codes.Unknown
+}
+
+// HTTPFromCode returns the HTTP response code corresponding to the given
+// gRPC code.
+func HTTPFromCode(code codes.Code) int {
+	if status, ok := httpForCode[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Code returns the gRPC code for err. The gRPC code of a registered
+// application error code takes precedence, followed by the code of an
+// existing gRPC status and the standard context errors. Otherwise the
+// HTTP response code of err, as returned by errgo.Code, is mapped with
+// CodeFromHTTP.
+func Code(err error) codes.Code {
+	if err == nil {
+		return codes.OK
+	}
+	if c := errgo.ErrorCodeOf(err); c != nil && c.GRPCCode != 0 {
+		return codes.Code(c.GRPCCode)
+	}
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &grpcErr) {
+		return grpcErr.GRPCStatus().Code()
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	}
+	return CodeFromHTTP(errgo.Code(err))
+}
+
+// Option configures the statuses built by Status.
+type Option func(*options)
+
+type options struct {
+	debugInfo bool
+}
+
+// WithDebugInfo adds a DebugInfo to the status details, whose stack
+// entries are the lines of errgo.ErrorStack and whose detail is the JSON
+// encoding of the error stack, so that FromStatus can restore it. As the
+// error stack holds internal messages and unredacted arguments, it should
+// only be sent to trusted clients.
+func WithDebugInfo() Option {
+	return func(o *options) {
+		o.debugInfo = true
+	}
+}
+
+// Status returns the gRPC status for err, or nil if err is nil. If err is
+// already a gRPC status error, its own status is returned. The status
+// message is the message to be shown to clients: the public message of
+// err, as returned by errgo.PublicMessage, or the message of its
+// application error code or the text of its HTTP response code. The
+// status details hold an ErrorInfo, whose reason is the application error
+// code of err, if any, and whose metadata holds its HTTP response code,
+// and a DebugInfo if the WithDebugInfo option is given.
+func Status(err error, opts ...Option) *status.Status {
+	if err == nil {
+		return nil
+	}
+	if err, ok := err.(interface{ GRPCStatus() *status.Status }); ok {
+		// The error is already a status error.
+		return err.GRPCStatus()
+	}
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	st := status.New(Code(err), message(err))
+	info := &errdetails.ErrorInfo{
+		Domain:   Domain,
+		Metadata: make(map[string]string),
+	}
+	if c := errgo.ErrorCodeOf(err); c != nil {
+		info.Reason = c.Code
+	}
+	if code := errgo.Code(err); code != 0 {
+		info.Metadata[httpStatusKey] = strconv.Itoa(code)
+	}
+	details := []protoadapt.MessageV1{info}
+	if o.debugInfo {
+		debug := &errdetails.DebugInfo{
+			StackEntries: strings.Split(errgo.ErrorStack(err), "\n"),
+		}
+		if data, jsonErr := json.Marshal(marshaler(err)); jsonErr == nil {
+			debug.Detail = string(data)
+		}
+		details = append(details, debug)
+	}
+	if withDetails, detailsErr := st.WithDetails(details...); detailsErr == nil {
+		st = withDetails
+	}
+	return st
+}
+
+// message returns the status message for err, which is safe to show to
+// clients.
+func message(err error) string {
+	if message := errgo.PublicMessage(err); message != "" {
+		return message
+	}
+	if c := errgo.ErrorCodeOf(err); c != nil && c.Message != "" {
+		return c.Message
+	}
+	code := errgo.Code(err)
+	if code == 0 {
+		code = HTTPFromCode(Code(err))
+	}
+	if text := http.StatusText(code); text != "" {
+		return text
+	}
+	return Code(err).String()
+}
+
+// marshaler returns err, or an *errgo.Err wrapping it if err cannot be
+// encoded as JSON itself.
+func marshaler(err error) json.Marshaler {
+	if m, ok := err.(json.Marshaler); ok {
+		return m
+	}
+	return errgo.Trace(err).(*errgo.Err)
+}
+
+// ToError returns the gRPC status error for err, as built by Status with
+// the given options, or nil if err is nil.
+func ToError(err error, opts ...Option) error {
+	if err == nil {
+		return nil
+	}
+	return Status(err, opts...).Err()
+}
+
+// FromStatus returns the errgo error for the gRPC status st, or nil if
+// st is OK. A status built by Status with the WithDebugInfo option is
+// restored with its full error stack. Otherwise the error has the status
+// message and the HTTP response code corresponding to the status code.
+func FromStatus(st *status.Status) error {
+	if st == nil || st.Code() == codes.OK {
+		return nil
+	}
+	httpStatus := HTTPFromCode(st.Code())
+	var errorCode *errgo.ErrorCode
+	for _, detail := range st.Details() {
+		switch detail := detail.(type) {
+		case *errdetails.DebugInfo:
+			if detail.Detail == "" {
+				continue
+			}
+			var e errgo.Err
+			if err := json.Unmarshal([]byte(detail.Detail), &e); err == nil {
+				return &e
+			}
+		case *errdetails.ErrorInfo:
+			if detail.Domain != Domain {
+				continue
+			}
+			if code, err := strconv.Atoi(detail.Metadata[httpStatusKey]); err == nil {
+				httpStatus = code
+			}
+			errorCode, _ = errgo.LookupCode(detail.Reason)
+		}
+	}
+	if errorCode != nil {
+		return errgo.Codef(errorCode, "%s", st.Message())
+	}
+	err := errgo.NewErr(httpStatus, "%s", st.Message())
+	err.SetLocation(1)
+	return &err
+}
+
+// FromError returns the errgo error for err if it is a gRPC status error,
+// as for FromStatus, or err unchanged otherwise.
+func FromError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	return FromStatus(st)
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor which converts any
+// error returned by a unary handler into a gRPC status with Status and
+// the given options.
+func UnaryServerInterceptor(opts ...Option) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return nil, ToError(err, opts...)
+		}
+		return resp, nil
+	}
+}
+
+// StreamServerInterceptor returns a gRPC interceptor which converts any
+// error returned by a stream handler into a gRPC status with Status and
+// the given options.
+func StreamServerInterceptor(opts ...Option) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return ToError(handler(srv, ss), opts...)
+	}
+}
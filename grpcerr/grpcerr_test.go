@@ -0,0 +1,254 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package grpcerr_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	jc "github.com/juju/testing/checkers"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	gc "gopkg.in/check.v1"
+
+	"github.com/hifx/errgo"
+	"github.com/hifx/errgo/grpcerr"
+)
+
+func Test(t *testing.T) {
+	gc.TestingT(t)
+}
+
+type grpcerrSuite struct{}
+
+var _ = gc.Suite(&grpcerrSuite{})
+
+var errQuotaExceeded = errgo.MustRegisterCode(errgo.ErrorCode{
+	Code:     "TEST_QUOTA_EXCEEDED",
+	Status:   http.StatusTooManyRequests,
+	GRPCCode: uint32(codes.ResourceExhausted),
+	Message:  "quota exceeded",
+})
+
+func (*grpcerrSuite) TestCode(c *gc.C) {
+	for i, test := range []struct {
+		err  error
+		code codes.Code
+	}{
+		{nil, codes.OK},
+		{fmt.Errorf("raw"), codes.Unknown},
+		{errgo.NotFoundf("user"), codes.NotFound},
+		{errgo.Annotate(errgo.Unauthorizedf("token"), "login"), codes.Unauthenticated},
+		{errgo.Mask(errgo.NotImplementedf("later")), codes.Unimplemented},
+		{errgo.MethodNotAllowedf("read only"), codes.Unimplemented},
+		{errgo.BadRequestf("bad"), codes.InvalidArgument},
+		{errgo.Forbiddenf("no"), codes.PermissionDenied},
+		{errgo.ServiceUnavailablef("down"), codes.Unavailable},
+		{errgo.InternalServerf("oops"), codes.Internal},
+		{errgo.Codef(errQuotaExceeded, ""), codes.ResourceExhausted},
+		{errgo.Annotate(context.DeadlineExceeded, "calling"), codes.DeadlineExceeded},
+		{errgo.Trace(context.Canceled), codes.Canceled},
+		{errgo.Trace(status.Error(codes.Aborted, "retry")), codes.Aborted},
+	} {
+		c.Logf("%v: %v", i, test.err)
+		c.Check(grpcerr.Code(test.err), gc.Equals, test.code)
+	}
+}
+
+func (*grpcerrSuite) TestCodeMappings(c *gc.C) {
+	c.Assert(grpcerr.CodeFromHTTP(http.StatusTeapot), gc.Equals, codes.FailedPrecondition)
+	c.Assert(grpcerr.CodeFromHTTP(599), gc.Equals, codes.Internal)
+	c.Assert(grpcerr.CodeFromHTTP(0), gc.Equals, codes.Unknown)
+	for _, code := range []codes.Code{
+		codes.InvalidArgument,
+		codes.DeadlineExceeded,
+		codes.NotFound,
+		codes.PermissionDenied,
+		codes.Unimplemented,
+		codes.Internal,
+		codes.Unavailable,
+		codes.Unauthenticated,
+	} {
+		c.Check(grpcerr.CodeFromHTTP(grpcerr.HTTPFromCode(code)), gc.Equals, code)
+	}
+}
+
+func (*grpcerrSuite) TestStatus(c *gc.C) {
+	c.Assert(grpcerr.Status(nil), gc.IsNil)
+	c.Assert(grpcerr.ToError(nil), gc.IsNil)
+
+	err := errgo.Annotate(errgo.Codef(errQuotaExceeded, "used %d", 10), "uploading")
+	st := grpcerr.Status(err)
+	c.Assert(st.Code(), gc.Equals, codes.ResourceExhausted)
+	c.Assert(st.Message(), gc.Equals, "quota exceeded")
+
+	details := st.Details()
+	c.Assert(details, gc.HasLen, 1)
+	info := details[0].(*errdetails.ErrorInfo)
+	c.Assert(info.Domain, gc.Equals, grpcerr.Domain)
+	c.Assert(info.Reason, gc.Equals, "TEST_QUOTA_EXCEEDED")
+	c.Assert(info.Metadata, jc.DeepEquals, map[string]string{"httpStatus": "429"})
+
+	details = grpcerr.Status(err, grpcerr.WithDebugInfo()).Details()
+	c.Assert(details, gc.HasLen, 2)
+	debug := details[1].(*errdetails.DebugInfo)
+	c.Assert(debug.StackEntries, jc.DeepEquals, err.(*errgo.Err).StackTrace())
+
+	existing := status.New(codes.Aborted, "retry")
+	c.Assert(grpcerr.Status(existing.Err()), jc.DeepEquals, existing)
+}
+
+func (*grpcerrSuite) TestStatusMessage(c *gc.C) {
+	for i, test := range []struct {
+		err     error
+		message string
+	}{
+		{errgo.WithPublicMessage(errgo.NotFoundf("row %d", 42), "Resource not found"), "Resource not found"},
+		{errgo.Annotate(errgo.Codef(errQuotaExceeded, "used %d", 10), "uploading"), "quota exceeded"},
+		{errgo.NotFoundf("user %q", "bob"), "Not Found"},
+		{errgo.AnnotateWith(fmt.Errorf("raw"), "dialing", errgo.F("password", errgo.Sensitive("hunter2"))), "Internal Server Error"},
+		{errgo.Trace(context.Canceled), "Canceled"},
+	} {
+		c.Logf("%v: %v", i, test.err)
+		st := grpcerr.Status(test.err)
+		c.Check(st.Message(), gc.Equals, test.message)
+		for _, detail := range st.Details() {
+			_, ok := detail.(*errdetails.DebugInfo)
+			c.Check(ok, jc.IsFalse)
+		}
+	}
+}
+
+func (*grpcerrSuite) TestRoundTrip(c *gc.C) {
+	for i, err := range []error{
+		errgo.NotFoundf("user %d", 42),
+		errgo.Annotate(errgo.NewUnauthorized(fmt.Errorf("expired"), "bad token"), "login"),
+		errgo.Wrap(errgo.New("first"), errgo.Gonef("deleted")),
+		errgo.Codef(errQuotaExceeded, ""),
+		fmt.Errorf("raw"),
+	} {
+		c.Logf("%v: %v", i, err)
+		obtained := grpcerr.FromError(grpcerr.ToError(err, grpcerr.WithDebugInfo()))
+		c.Check(obtained.Error(), gc.Equals, err.Error())
+		c.Check(errgo.Code(obtained), gc.Equals, errgo.Code(err))
+		c.Check(errgo.ErrorCodeOf(obtained), gc.Equals, errgo.ErrorCodeOf(err))
+		c.Check(grpcerr.Code(obtained), gc.Equals, grpcerr.Code(err))
+	}
+}
+
+func (*grpcerrSuite) TestFromStatus(c *gc.C) {
+	c.Assert(grpcerr.FromStatus(nil), gc.IsNil)
+	c.Assert(grpcerr.FromStatus(status.New(codes.OK, "")), gc.IsNil)
+
+	err := grpcerr.FromStatus(status.New(codes.NotFound, "no such user"))
+	c.Assert(err, gc.ErrorMatches, "no such user")
+	c.Assert(errgo.IsNotFound(err), jc.IsTrue)
+
+	st, _ := status.New(codes.ResourceExhausted, "slow down").WithDetails(&errdetails.ErrorInfo{
+		Domain:   grpcerr.Domain,
+		Reason:   "TEST_QUOTA_EXCEEDED",
+		Metadata: map[string]string{"httpStatus": "429"},
+	})
+	err = grpcerr.FromStatus(st)
+	c.Assert(err, gc.ErrorMatches, "slow down")
+	c.Assert(errgo.ErrorCodeOf(err), gc.Equals, errQuotaExceeded)
+
+	plain := fmt.Errorf("not a status")
+	c.Assert(grpcerr.FromError(plain), gc.Equals, plain)
+}
+
+// healthServer is a health service whose methods return errgo errors.
+type healthServer struct {
+	healthpb.UnimplementedHealthServer
+	err error
+}
+
+func (s *healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func (s *healthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	return s.err
+}
+
+// startServer starts an in-process health server using the grpcerr
+// interceptors with the given options, returning a client for it and a
+// function which stops it.
+func startServer(c *gc.C, srv *healthServer, opts ...grpcerr.Option) (healthpb.HealthClient, func()) {
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(grpcerr.UnaryServerInterceptor(opts...)),
+		grpc.StreamInterceptor(grpcerr.StreamServerInterceptor(opts...)),
+	)
+	healthpb.RegisterHealthServer(server, srv)
+	go server.Serve(lis)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	c.Assert(err, gc.IsNil)
+	return healthpb.NewHealthClient(conn), func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+func (*grpcerrSuite) TestInterceptors(c *gc.C) {
+	srv := &healthServer{}
+	client, stop := startServer(c, srv, grpcerr.WithDebugInfo())
+	defer stop()
+	ctx := context.Background()
+
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	c.Assert(err, gc.IsNil)
+	c.Assert(resp.Status, gc.Equals, healthpb.HealthCheckResponse_SERVING)
+
+	srv.err = errgo.Annotate(errgo.NotFoundf("service %q", "billing"), "checking")
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{})
+	c.Assert(status.Code(err), gc.Equals, codes.NotFound)
+	restored := grpcerr.FromError(err)
+	c.Assert(restored.Error(), gc.Equals, `checking: service "billing"`)
+	c.Assert(errgo.IsNotFound(restored), jc.IsTrue)
+	c.Assert(errgo.ErrorStack(restored), gc.Equals, errgo.ErrorStack(srv.err))
+
+	srv.err = errgo.Unauthorizedf("no token")
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	c.Assert(err, gc.IsNil)
+	_, err = stream.Recv()
+	c.Assert(status.Code(err), gc.Equals, codes.Unauthenticated)
+	c.Assert(errgo.IsUnauthorized(grpcerr.FromError(err)), jc.IsTrue)
+}
+
+func (*grpcerrSuite) TestInterceptorsWithoutDebugInfo(c *gc.C) {
+	srv := &healthServer{
+		err: errgo.AnnotateWith(errgo.NotFoundf("service %q", "billing"), "checking", errgo.F("token", errgo.Sensitive("secret"))),
+	}
+	client, stop := startServer(c, srv)
+	defer stop()
+
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	c.Assert(status.Code(err), gc.Equals, codes.NotFound)
+	st, _ := status.FromError(err)
+	c.Assert(st.Message(), gc.Equals, "Not Found")
+	for _, detail := range st.Details() {
+		_, ok := detail.(*errdetails.DebugInfo)
+		c.Assert(ok, jc.IsFalse)
+	}
+	restored := grpcerr.FromError(err)
+	c.Assert(restored, gc.ErrorMatches, "Not Found")
+	c.Assert(errgo.IsNotFound(restored), jc.IsTrue)
+}
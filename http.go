@@ -0,0 +1,177 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+)
+
+// HandlerFunc is an HTTP handler that returns an error instead of writing
+// the error response itself. It implements http.Handler, rendering any
+// returned error with WriteLocalizedError and recovering any panic as an
+// error which satisfies IsInternalServer().
+//
+// For example:
+//     http.Handle("/users/", errgo.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+//         user, err := findUser(r.URL.Path)
+//         if err != nil {
+//             return errgo.NewNotFound(err, "user not found")
+//         }
+//         return json.NewEncoder(w).Encode(user)
+//     }))
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// ServeHTTP implements http.Handler. The error response is only written if
+// the handler has not already started writing a response of its own, so
+// the response is written exactly once. An error returned after the
+// response has been started is logged with its ErrorStack instead. A panic
+// after the response has been started is logged too, and the response is
+// aborted by panicking with http.ErrAbortHandler, so that the client does
+// not take the truncated response for a complete one.
+func (f HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rw := &responseWriter{ResponseWriter: w}
+	panicked, err := f.call(rw, r)
+	if err == nil {
+		return
+	}
+	if !rw.wroteHeader {
+		writeError(rw, err, ParseAcceptLanguage(r.Header.Get("Accept-Language")))
+		return
+	}
+	if panicked {
+		log.Printf("errgo: panic serving %s %s after the response was started: %s", r.Method, r.URL.Path, ErrorStack(err))
+		panic(http.ErrAbortHandler)
+	}
+	log.Printf("errgo: error serving %s %s after the response was started: %s", r.Method, r.URL.Path, ErrorStack(err))
+}
+
+// call calls f, returning whether it panicked and any panic as an error.
+func (f HandlerFunc) call(w http.ResponseWriter, r *http.Request) (panicked bool, err error) {
+	defer func() {
+		v := recover()
+		if v == nil {
+			return
+		}
+		if v == http.ErrAbortHandler {
+			// This panic is used to abort the response, and is
+			// handled by the server.
+			panic(v)
+		}
+		panicked, err = true, newPanicError(v)
+	}()
+	return false, f(w, r)
+}
+
+// RecoverHandler returns an http.Handler that calls h, recovering any
+// panic as an error which satisfies IsInternalServer() and rendering it
+// with WriteLocalizedError, unless h had already started writing the
+// response, in which case the response is aborted as for HandlerFunc.
+func RecoverHandler(h http.Handler) http.Handler {
+	return HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		h.ServeHTTP(w, r)
+		return nil
+	})
+}
+
+// WriteError writes err to w as an HTTP response. The status code, content
+// type and body are taken from the error in the stack of err whose HTTP
+// code is returned by Code. The body is the message of that error, so
+// annotations and any wrapped errors are not exposed, with sensitive data
+// redacted as for SafeError. If the stack of err holds a public message,
+// as returned by PublicMessage, that is written as plain text instead. An
+// error without an HTTP code is written as a plain 500 Internal Server
+// Error.
+func WriteError(w http.ResponseWriter, err error) {
+	writeError(w, err, nil)
+}
+
+// WriteLocalizedError is like WriteError, but if a translation of the
+// message of err into a language accepted by r has been registered with
+// RegisterTranslation, the body is that translation, as returned by
+// Localize, written as plain text.
+func WriteLocalizedError(w http.ResponseWriter, r *http.Request, err error) {
+	writeError(w, err, ParseAcceptLanguage(r.Header.Get("Accept-Language")))
+}
+
+// writeError writes err to w as for WriteError, translating the message
+// into the first of tags that has a translation.
+func writeError(w http.ResponseWriter, err error, tags []string) {
+	code := http.StatusInternalServerError
+	contentType := "text/plain; charset=utf-8"
+	body := http.StatusText(code)
+	if e := coderOf(err); e != nil {
+		code = e.Code()
+		body = codedMessage(e)
+		if e, ok := e.(contentTyper); ok && e.ContentType() != "" {
+			contentType = e.ContentType()
+		}
+	}
+	if message := PublicMessage(err); message != "" {
+		contentType = "text/plain; charset=utf-8"
+		body = message
+	}
+	if message, ok := localize(err, tags); ok {
+		contentType = "text/plain; charset=utf-8"
+		body = message
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	fmt.Fprint(w, body)
+}
+
+// codedMessage returns the message shown to clients for e, the error
+// carrying the HTTP response code of an error stack: its own message with
+// sensitive data redacted, or its whole message if it has none of its
+// own.
+func codedMessage(e coderError) string {
+	if message := safeMessage(e); message != "" {
+		return message
+	}
+	return SafeError(e)
+}
+
+// responseWriter wraps an http.ResponseWriter, recording whether a
+// response has been started.
+type responseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush implements http.Flusher if the wrapped writer does.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if the wrapped writer does.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, Errorf("%T does not implement http.Hijacker", w.ResponseWriter)
+	}
+	w.wroteHeader = true
+	return h.Hijack()
+}
+
+// Unwrap returns the wrapped writer, for use by http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
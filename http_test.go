@@ -0,0 +1,220 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+
+	gc "gopkg.in/check.v1"
+
+	"github.com/hifx/errgo"
+)
+
+type httpSuite struct{}
+
+var _ = gc.Suite(&httpSuite{})
+
+func (*httpSuite) TestHandlerFunc(c *gc.C) {
+	for i, test := range []struct {
+		message     string
+		handler     errgo.HandlerFunc
+		code        int
+		contentType string
+		body        string
+	}{{
+		message: "no error",
+		handler: func(w http.ResponseWriter, r *http.Request) error {
+			io.WriteString(w, "ok")
+			return nil
+		},
+		code:        http.StatusOK,
+		contentType: "text/plain; charset=utf-8",
+		body:        "ok",
+	}, {
+		message: "http error",
+		handler: func(w http.ResponseWriter, r *http.Request) error {
+			return errgo.Annotate(errgo.NotFoundf("user %d", 42), "loading")
+		},
+		code:        http.StatusNotFound,
+		contentType: "text/plain; charset=utf-8",
+		body:        "user 42",
+	}, {
+		message: "http error wrapping another",
+		handler: func(w http.ResponseWriter, r *http.Request) error {
+			return errgo.NewUnauthorized(fmt.Errorf("token expired"), "access denied")
+		},
+		code:        http.StatusUnauthorized,
+		contentType: "text/plain; charset=utf-8",
+		body:        "access denied",
+	}, {
+		message: "json error",
+		handler: func(w http.ResponseWriter, r *http.Request) error {
+			err := errgo.NewJSONErrWithCause(fmt.Errorf("duplicate key"), http.StatusConflict, `{"error":"exists"}`)
+			return &err
+		},
+		code:        http.StatusConflict,
+		contentType: "application/json; charset=utf-8",
+		body:        `{"error":"exists"}`,
+	}, {
+		message: "http error with sensitive argument",
+		handler: func(w http.ResponseWriter, r *http.Request) error {
+			return errgo.BadRequestf("invalid email %q", errgo.Sensitive("jo@example.com"))
+		},
+		code:        http.StatusBadRequest,
+		contentType: "text/plain; charset=utf-8",
+		body:        "invalid email [REDACTED]",
+	}, {
+		message: "http error with public message",
+		handler: func(w http.ResponseWriter, r *http.Request) error {
+			return errgo.NewPublic(http.StatusNotFound, "Resource not found", "row %d missing in table %s", 42, "users")
+		},
+		code:        http.StatusNotFound,
+		contentType: "text/plain; charset=utf-8",
+		body:        "Resource not found",
+	}, {
+		message: "json error with public message",
+		handler: func(w http.ResponseWriter, r *http.Request) error {
+			err := errgo.NewJSONErrWithCause(fmt.Errorf("duplicate key"), http.StatusConflict, `{"error":"exists"}`)
+			return errgo.WithPublicMessage(&err, "Already exists")
+		},
+		code:        http.StatusConflict,
+		contentType: "text/plain; charset=utf-8",
+		body:        "Already exists",
+	}, {
+		message: "plain error with public message",
+		handler: func(w http.ResponseWriter, r *http.Request) error {
+			return errgo.WithPublicMessage(fmt.Errorf("database down"), "Please try again later")
+		},
+		code:        http.StatusInternalServerError,
+		contentType: "text/plain; charset=utf-8",
+		body:        "Please try again later",
+	}, {
+		message: "plain error",
+		handler: func(w http.ResponseWriter, r *http.Request) error {
+			return fmt.Errorf("database password is hunter2")
+		},
+		code:        http.StatusInternalServerError,
+		contentType: "text/plain; charset=utf-8",
+		body:        "Internal Server Error",
+	}, {
+		message: "panic",
+		handler: func(w http.ResponseWriter, r *http.Request) error {
+			panic("boom")
+		},
+		code:        http.StatusInternalServerError,
+		contentType: "text/plain; charset=utf-8",
+		body:        "panic",
+	}} {
+		c.Logf("%v: %s", i, test.message)
+		rec := httptest.NewRecorder()
+		test.handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		c.Check(rec.Code, gc.Equals, test.code)
+		c.Check(rec.Header().Get("Content-Type"), gc.Equals, test.contentType)
+		c.Check(rec.Body.String(), gc.Equals, test.body)
+	}
+}
+
+func (*httpSuite) TestRecoverHandler(c *gc.C) {
+	h := errgo.RecoverHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic(fmt.Errorf("boom"))
+		}
+		io.WriteString(w, "ok")
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusOK)
+	c.Assert(rec.Body.String(), gc.Equals, "ok")
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/panic", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusInternalServerError)
+	c.Assert(rec.Body.String(), gc.Equals, "panic")
+}
+
+func (*httpSuite) TestRecoverHandlerAbortHandler(c *gc.C) {
+	h := errgo.RecoverHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	c.Assert(func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}, gc.PanicMatches, "net/http: abort Handler")
+}
+
+func (*httpSuite) TestErrorAfterWriting(c *gc.C) {
+	var logged bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logged)
+	h := errgo.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, "partial")
+		return errgo.BadRequestf("too late")
+	})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/users", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusAccepted)
+	c.Assert(rec.Header().Get("Content-Type"), gc.Equals, "")
+	c.Assert(rec.Body.String(), gc.Equals, "partial")
+	c.Assert(logged.String(), gc.Matches, `(?s).*errgo: error serving GET /users after the response was started: .*http_test.go:\d+ .*: too late\n`)
+}
+
+func (*httpSuite) TestPanicAfterWriting(c *gc.C) {
+	var logged bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logged)
+	h := errgo.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		io.WriteString(w, "partial")
+		panic("boom")
+	})
+	rec := httptest.NewRecorder()
+	c.Assert(func() {
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/users", nil))
+	}, gc.PanicMatches, "net/http: abort Handler")
+	c.Assert(rec.Body.String(), gc.Equals, "partial")
+	c.Assert(logged.String(), gc.Matches, `(?s).*errgo: panic serving GET /users after the response was started: .*boom.*`)
+}
+
+func (*httpSuite) TestHandlerFuncFlush(c *gc.C) {
+	h := errgo.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		w.(http.Flusher).Flush()
+		return nil
+	})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	c.Assert(rec.Flushed, gc.Equals, true)
+}
+
+func (*httpSuite) TestHandlerFuncHijack(c *gc.C) {
+	server := httptest.NewServer(errgo.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return errgo.Trace(err)
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		return buf.Flush()
+	}))
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	c.Assert(err, gc.IsNil)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(body), gc.Equals, "hijacked")
+
+	// A writer which cannot be hijacked gives an error.
+	h := errgo.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		_, _, err := w.(http.Hijacker).Hijack()
+		return err
+	})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	c.Assert(rec.Code, gc.Equals, http.StatusInternalServerError)
+}
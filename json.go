@@ -0,0 +1,224 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// jsonErr is the JSON representation of an error in an error stack.
+type jsonErr struct {
+	Message       string                 `json:"message"`
+	SafeMessage   string                 `json:"safeMessage,omitempty"`
+	Format        string                 `json:"format,omitempty"`
+	PublicMessage string                 `json:"publicMessage,omitempty"`
+	Code          int                    `json:"code,omitempty"`
+	ErrorCode     string                 `json:"errorCode,omitempty"`
+	ContentType   string                 `json:"contentType,omitempty"`
+	Location      *jsonLocation          `json:"location,omitempty"`
+	Fields        []jsonField            `json:"fields,omitempty"`
+	TemplateArgs  map[string]interface{} `json:"templateArgs,omitempty"`
+	Context       map[string]string      `json:"context,omitempty"`
+	Masked        bool                   `json:"masked,omitempty"`
+	Retryable     *bool                  `json:"retryable,omitempty"`
+	RetryAfter    string                 `json:"retryAfter,omitempty"`
+	Previous      *jsonErr               `json:"previous,omitempty"`
+	Cause         *jsonErr               `json:"cause,omitempty"`
+	Errors        []*jsonErr             `json:"errors,omitempty"`
+}
+
+// jsonField is the JSON representation of a field.
+type jsonField struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// jsonLocation is the JSON representation of the location of an error.
+type jsonLocation struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Function string `json:"function,omitempty"`
+}
+
+type coder interface {
+	Code() int
+}
+
+type contentTyper interface {
+	ContentType() string
+}
+
+// MarshalJSON implements json.Marshaler. The error is written as an object
+// holding its message, code, application error code, content type,
+// location and fields, with the previous error and the cause, if any, and
+// the errors held by a MultiError nested as objects of the same form.
+// Errors from outside this package only record the result of their Error
+// method.
+func (e *Err) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newJSONErr(e))
+}
+
+// UnmarshalJSON implements json.Unmarshaler, reading an error written by
+// MarshalJSON. Every error in the stack is restored as an *Err, or a
+// *MultiError if it held other errors, so while the messages, locations
+// and codes survive, the types of errors from outside this package do
+// not.
+func (e *Err) UnmarshalJSON(data []byte) error {
+	var j jsonErr
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*e = *j.err()
+	return nil
+}
+
+// newJSONErr returns the JSON representation of err and the errors it
+// wraps.
+func newJSONErr(err error) *jsonErr {
+	if err == nil {
+		return nil
+	}
+	var j jsonErr
+	if err, ok := err.(locationer); ok {
+		file, function, line := err.Location()
+		if file != "" {
+			j.Location = &jsonLocation{
+				File:     file,
+				Line:     line,
+				Function: function,
+			}
+		}
+	}
+	if err, ok := err.(coder); ok {
+		j.Code = err.Code()
+	}
+	if err, ok := err.(contentTyper); ok {
+		j.ContentType = err.ContentType()
+	}
+	if err, ok := err.(errorCoder); ok && err.ErrorCode() != nil {
+		j.ErrorCode = err.ErrorCode().Code
+	}
+	if err, ok := err.(fielder); ok {
+		for _, field := range err.Fields() {
+			j.Fields = append(j.Fields, jsonField{field.Key, jsonValue(field.Value)})
+		}
+	}
+	if err, ok := err.(templateArger); ok && err.TemplateArgs() != nil {
+		j.TemplateArgs = make(map[string]interface{})
+		for name, arg := range err.TemplateArgs() {
+			j.TemplateArgs[name] = jsonValue(arg)
+		}
+	}
+	if err, ok := err.(contextValuer); ok {
+		j.Context = err.ContextValues()
+	}
+	if err, ok := err.(retryClassifier); ok {
+		if retryable, ok := err.Retryable(); ok {
+			j.Retryable = &retryable
+		}
+	}
+	if err, ok := err.(retryAfterer); ok && err.RetryAfter() > 0 {
+		j.RetryAfter = err.RetryAfter().String()
+	}
+	if err, ok := err.(*Err); ok {
+		j.SafeMessage = err.safeMessage
+		if err.format != err.message {
+			j.Format = err.format
+		}
+		j.PublicMessage = err.publicMessage
+		j.Masked = err.masked
+	}
+	if err, ok := err.(causer); ok {
+		j.Cause = newJSONErr(err.Cause())
+	}
+	if err, ok := err.(multiError); ok {
+		for _, err := range err.Errors() {
+			j.Errors = append(j.Errors, newJSONErr(err))
+		}
+	}
+	if cerr, ok := err.(wrapper); ok {
+		j.Message = cerr.Message()
+		j.Previous = newJSONErr(cerr.Underlying())
+	} else {
+		j.Message = err.Error()
+	}
+	return &j
+}
+
+// jsonValue returns v if it can be encoded as JSON, or its text, as
+// formatted by fmt.Sprint, otherwise, such as for channels, functions and
+// cyclic values, so that a single field cannot prevent the encoding of
+// the error.
+func jsonValue(v interface{}) interface{} {
+	if _, err := json.Marshal(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return v
+}
+
+// err returns the *Err represented by j.
+func (j *jsonErr) err() *Err {
+	e := &Err{
+		message:       j.Message,
+		safeMessage:   j.SafeMessage,
+		format:        j.Format,
+		publicMessage: j.PublicMessage,
+		code:          j.Code,
+		contentType:   j.ContentType,
+		masked:        j.Masked,
+		templateArgs:  j.TemplateArgs,
+		contextValues: j.Context,
+	}
+	if j.ErrorCode != "" {
+		// Codes unknown to this process are dropped.
+		e.errorCode, _ = LookupCode(j.ErrorCode)
+	}
+	if j.Retryable != nil {
+		e.SetRetryable(*j.Retryable)
+	}
+	if j.RetryAfter != "" {
+		// A malformed delay is dropped.
+		e.retryAfter, _ = time.ParseDuration(j.RetryAfter)
+	}
+	for _, field := range j.Fields {
+		e.fields = append(e.fields, Field{field.Key, field.Value})
+	}
+	if j.Location != nil {
+		e.file = j.Location.File
+		e.line = j.Location.Line
+		e.function = j.Location.Function
+	}
+	// Assign the nested errors only when present, so that the interface
+	// values stay nil rather than holding a nil *Err.
+	if j.Previous != nil {
+		e.previous = j.Previous.error()
+	}
+	if j.Cause != nil {
+		e.cause = j.Cause.error()
+	}
+	return e
+}
+
+// error returns the error represented by j: a *MultiError if it holds
+// other errors, or an *Err otherwise.
+func (j *jsonErr) error() error {
+	if len(j.Errors) == 0 {
+		return j.err()
+	}
+	return &MultiError{
+		Err:  *j.err(),
+		errs: j.errors(),
+	}
+}
+
+// errors returns the errors held by the MultiError represented by j.
+func (j *jsonErr) errors() []error {
+	var errs []error
+	for _, j := range j.Errors {
+		errs = append(errs, j.error())
+	}
+	return errs
+}
@@ -0,0 +1,129 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo_test
+
+import (
+	"encoding/json"
+	"fmt"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/hifx/errgo"
+)
+
+type jsonSuite struct{}
+
+var _ = gc.Suite(&jsonSuite{})
+
+func (*jsonSuite) TestMarshalJSON(c *gc.C) {
+	err := errgo.NewNotFound(fmt.Errorf("no rows"), "user not found")
+	file, function, line := err.(*errgo.Err).Location()
+
+	data, jsonErr := json.Marshal(errgo.Mask(err))
+	c.Assert(jsonErr, gc.IsNil)
+
+	var obtained map[string]interface{}
+	c.Assert(json.Unmarshal(data, &obtained), gc.IsNil)
+	location := obtained["location"].(map[string]interface{})
+	delete(obtained, "location")
+	c.Assert(location["file"], gc.Equals, "github.com/hifx/errgo/json_test.go")
+	c.Assert(obtained, jc.DeepEquals, map[string]interface{}{
+		"message": "",
+		"masked":  true,
+		"previous": map[string]interface{}{
+			"message": "user not found",
+			"code":    float64(404),
+			"location": map[string]interface{}{
+				"file":     file,
+				"line":     float64(line),
+				"function": function,
+			},
+			"previous": map[string]interface{}{
+				"message": "no rows",
+			},
+		},
+	})
+}
+
+func (*jsonSuite) TestRoundTrip(c *gc.C) {
+	for i, test := range []struct {
+		message   string
+		generator func() error
+	}{{
+		message: "New",
+		generator: func() error {
+			return errgo.New("first error")
+		},
+	}, {
+		message: "traced, and annotated",
+		generator: func() error {
+			err := errgo.New("first error")
+			err = errgo.Trace(err)
+			err = errgo.Annotate(err, "some context")
+			return errgo.Trace(err)
+		},
+	}, {
+		message: "uncomparable, wrapped, masked and annotated",
+		generator: func() error {
+			err := newNonComparableError("first error")
+			err = errgo.Trace(err)
+			err = errgo.Wrap(err, newError("value error"))
+			err = errgo.Maskf(err, "masked")
+			err = errgo.Annotate(err, "more context")
+			return errgo.Trace(err)
+		},
+	}, {
+		message: "embedded with cause",
+		generator: func() error {
+			return errgo.Trace(newEmbedWithCause(fmt.Errorf("external error"), "testing %d", 43))
+		},
+	}, {
+		message: "http error",
+		generator: func() error {
+			err := errgo.NewUnauthorized(fmt.Errorf("bad token"), "access denied")
+			return errgo.Annotate(err, "checking token")
+		},
+	}, {
+		message: "json error",
+		generator: func() error {
+			err := errgo.NewJSONErrWithCause(errgo.New("first error"), 409, `{"conflict":true}`)
+			return &err
+		},
+	}} {
+		c.Logf("%v: %s", i, test.message)
+		err := test.generator()
+		data, jsonErr := json.Marshal(err)
+		c.Assert(jsonErr, gc.IsNil)
+
+		var obtained errgo.Err
+		c.Assert(json.Unmarshal(data, &obtained), gc.IsNil)
+		c.Check(obtained.Error(), gc.Equals, err.Error())
+		c.Check(errgo.ErrorStack(&obtained), gc.Equals, errgo.ErrorStack(err))
+		c.Check(errgo.Details(&obtained), gc.Equals, errgo.Details(err))
+		c.Check(errgo.Cause(&obtained).Error(), gc.Equals, errgo.Cause(err).Error())
+		if err, ok := err.(*errgo.Err); ok {
+			c.Check(obtained.Code(), gc.Equals, err.Code())
+			c.Check(obtained.ContentType(), gc.Equals, err.ContentType())
+		}
+
+		again, jsonErr := json.Marshal(&obtained)
+		c.Assert(jsonErr, gc.IsNil)
+		c.Check(string(again), gc.Equals, string(data))
+	}
+}
+
+func (*jsonSuite) TestRoundTripPredicates(c *gc.C) {
+	data, err := json.Marshal(errgo.NotFoundf("no %s", "user"))
+	c.Assert(err, gc.IsNil)
+	var obtained errgo.Err
+	c.Assert(json.Unmarshal(data, &obtained), gc.IsNil)
+	c.Assert(errgo.IsNotFound(&obtained), jc.IsTrue)
+	c.Assert(errgo.IsBadRequest(&obtained), jc.IsFalse)
+}
+
+func (*jsonSuite) TestUnmarshalInvalid(c *gc.C) {
+	var obtained errgo.Err
+	c.Assert(json.Unmarshal([]byte(`{"message": 42}`), &obtained), gc.NotNil)
+}
@@ -0,0 +1,235 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// catalog holds the registered translations, keyed by message key and
+// then by lower case language tag.
+var catalog = struct {
+	mu           sync.RWMutex
+	translations map[string]map[string]*template.Template
+}{
+	translations: make(map[string]map[string]*template.Template),
+}
+
+// RegisterTranslation registers the translation of the messages for key
+// into the language with the given tag, such as "fr" or "pt-BR". The key
+// is either the code of an application error code registered with
+// RegisterCode, or the decimal HTTP response code of errors which have no
+// application error code, such as "404". The translation is a
+// text/template template executed with the template arguments of the
+// error, as returned by TemplateArgs. Registering a translation for the
+// same key and tag again replaces it.
+//
+// For example:
+//     errgo.RegisterTranslation("QUOTA_EXCEEDED", "fr", "Quota de {{.limit}} requêtes dépassé")
+func RegisterTranslation(key, tag, translation string) error {
+	if key == "" || tag == "" {
+		return New("translation must have a key and a language tag")
+	}
+	t, err := template.New(key + "/" + tag).Parse(translation)
+	if err != nil {
+		return Annotatef(err, "cannot parse translation of %q into %q", key, tag)
+	}
+	catalog.mu.Lock()
+	defer catalog.mu.Unlock()
+	if catalog.translations[key] == nil {
+		catalog.translations[key] = make(map[string]*template.Template)
+	}
+	catalog.translations[key][strings.ToLower(tag)] = t
+	return nil
+}
+
+// MustRegisterTranslation is like RegisterTranslation but panics if the
+// translation cannot be registered. It is intended for use in package
+// initialization.
+func MustRegisterTranslation(key, tag, translation string) {
+	if err := RegisterTranslation(key, tag, translation); err != nil {
+		panic(err)
+	}
+}
+
+// WithTemplateArgs returns a new error in the stack of other, keeping its
+// cause, which carries arguments for the templates of the translations of
+// its message. The location of the call is recorded. WithTemplateArgs
+// returns nil if other is nil.
+//
+// For example:
+//   if used > limit {
+//       return errgo.WithTemplateArgs(errgo.Codef(ErrQuotaExceeded, ""), map[string]interface{}{
+//           "limit": limit,
+//       })
+//   }
+//
+func WithTemplateArgs(other error, args map[string]interface{}) error {
+	if other == nil {
+		return nil
+	}
+	err := &Err{
+		previous:     other,
+		cause:        Cause(other),
+		templateArgs: args,
+	}
+	err.SetLocation(1)
+	return err
+}
+
+// SetTemplateArgs sets the template arguments for this error.
+func (e *Err) SetTemplateArgs(args map[string]interface{}) {
+	e.templateArgs = args
+}
+
+// TemplateArgs returns the template arguments attached to this entry in
+// the error stack.
+func (e *Err) TemplateArgs() map[string]interface{} {
+	return e.templateArgs
+}
+
+type templateArger interface {
+	TemplateArgs() map[string]interface{}
+}
+
+var _ templateArger = (*Err)(nil)
+
+// TemplateArgs returns the template arguments attached to all the errors
+// in the stack of err, searched as for Code, merged into one map. Where an
+// argument was attached more than once the most recent value is used.
+func TemplateArgs(err error) map[string]interface{} {
+	var result map[string]interface{}
+	visitErrors(err, func(e error) {
+		e1, ok := e.(templateArger)
+		if !ok {
+			return
+		}
+		for name, value := range e1.TemplateArgs() {
+			if result == nil {
+				result = make(map[string]interface{})
+			}
+			if _, ok := result[name]; !ok {
+				result[name] = value
+			}
+		}
+	})
+	return result
+}
+
+// Localize returns the message of err translated into the first of the
+// given language tags, in order of preference, for which a translation
+// has been registered with RegisterTranslation. A tag without a
+// translation of its own matches the translation for its parent language,
+// so "fr-CA" matches "fr". The translation is chosen by the application
+// error code of err, as returned by ErrorCodeOf, or otherwise by its HTTP
+// response code, as returned by Code.
+//
+// If there is no matching translation, or it fails to execute, Localize
+// returns the untranslated message: the public message of err, if any, or
+// otherwise the message of the error in the stack with the HTTP code, as
+// for WriteError, or otherwise the message of err, with sensitive data
+// redacted.
+func Localize(err error, tags ...string) string {
+	if message, ok := localize(err, tags); ok {
+		return message
+	}
+	if message := PublicMessage(err); message != "" {
+		return message
+	}
+	if e := coderOf(err); e != nil {
+		if message := safeMessage(e); message != "" {
+			return message
+		}
+		return SafeError(e)
+	}
+	return SafeError(err)
+}
+
+// localize returns the message of err translated into the first matching
+// language tag, and whether there was one.
+func localize(err error, tags []string) (string, bool) {
+	if err == nil || len(tags) == 0 {
+		return "", false
+	}
+	key := strconv.Itoa(Code(err))
+	if c := ErrorCodeOf(err); c != nil {
+		key = c.Code
+	}
+	catalog.mu.RLock()
+	translations := catalog.translations[key]
+	catalog.mu.RUnlock()
+	if translations == nil {
+		return "", false
+	}
+	for _, tag := range tags {
+		tag = strings.ToLower(tag)
+		for tag != "" {
+			if t, ok := translations[tag]; ok {
+				var buf strings.Builder
+				if execErr := t.Execute(&buf, TemplateArgs(err)); execErr != nil {
+					return "", false
+				}
+				return buf.String(), true
+			}
+			i := strings.LastIndex(tag, "-")
+			if i < 0 {
+				break
+			}
+			tag = tag[:i]
+		}
+	}
+	return "", false
+}
+
+// ParseAcceptLanguage returns the language tags of an HTTP Accept-Language
+// header in order of preference, omitting the wildcard and any tags with a
+// quality of zero or a malformed quality, such as NaN or a value outside
+// the range [0, 1].
+//
+// For example, ParseAcceptLanguage("fr-CH, fr;q=0.9, en;q=0.8, *;q=0.5")
+// returns []string{"fr-CH", "fr", "en"}.
+func ParseAcceptLanguage(header string) []string {
+	type weightedTag struct {
+		tag     string
+		quality float64
+	}
+	var weighted []weightedTag
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if name != "q" {
+				continue
+			}
+			q, err := strconv.ParseFloat(value, 64)
+			if err != nil || math.IsNaN(q) || q < 0 || q > 1 {
+				// Malformed and out of range weights exclude the tag.
+				q = 0
+			}
+			quality = q
+		}
+		if quality <= 0 {
+			continue
+		}
+		weighted = append(weighted, weightedTag{tag, quality})
+	}
+	sort.SliceStable(weighted, func(i, j int) bool {
+		return weighted[i].quality > weighted[j].quality
+	})
+	var tags []string
+	for _, w := range weighted {
+		tags = append(tags, w.tag)
+	}
+	return tags
+}
@@ -0,0 +1,204 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/hifx/errgo"
+)
+
+type localizeSuite struct {
+	restore func()
+}
+
+var _ = gc.Suite(&localizeSuite{})
+
+var errQuotaExceeded = errgo.MustRegisterCode(errgo.ErrorCode{
+	Code:    "LOCALIZE_QUOTA_EXCEEDED",
+	Status:  http.StatusTooManyRequests,
+	Message: "quota exceeded",
+})
+
+func (s *localizeSuite) SetUpTest(c *gc.C) {
+	s.restore = errgo.ResetTranslations()
+	errgo.MustRegisterTranslation("LOCALIZE_QUOTA_EXCEEDED", "fr", "Quota de {{.limit}} requêtes dépassé")
+	errgo.MustRegisterTranslation("LOCALIZE_QUOTA_EXCEEDED", "pt-BR", "Cota de {{.limit}} solicitações excedida")
+	errgo.MustRegisterTranslation("404", "fr", "Introuvable")
+}
+
+func (s *localizeSuite) TearDownTest(c *gc.C) {
+	s.restore()
+}
+
+func (*localizeSuite) TestRegisterTranslation(c *gc.C) {
+	err := errgo.RegisterTranslation("", "fr", "x")
+	c.Assert(err, gc.ErrorMatches, "translation must have a key and a language tag")
+	err = errgo.RegisterTranslation("404", "", "x")
+	c.Assert(err, gc.ErrorMatches, "translation must have a key and a language tag")
+	err = errgo.RegisterTranslation("404", "de", "{{.unclosed")
+	c.Assert(err, gc.ErrorMatches, `cannot parse translation of "404" into "de": .*`)
+	c.Assert(func() { errgo.MustRegisterTranslation("404", "de", "{{") }, gc.PanicMatches, "cannot parse translation .*")
+}
+
+func (*localizeSuite) TestTemplateArgs(c *gc.C) {
+	c.Assert(errgo.TemplateArgs(fmt.Errorf("raw")), gc.IsNil)
+
+	err := errgo.WithTemplateArgs(errgo.New("first"), map[string]interface{}{"limit": 10, "user": "jo"})
+	err = errgo.Annotate(err, "annotation")
+	err = errgo.WithTemplateArgs(err, map[string]interface{}{"limit": 20})
+	c.Assert(errgo.TemplateArgs(err), jc.DeepEquals, map[string]interface{}{"limit": 20, "user": "jo"})
+	c.Assert(errgo.WithTemplateArgs(nil, nil), gc.IsNil)
+
+	// Arguments are found through other errors.
+	err = fmt.Errorf("wrapped: %w", errgo.WithTemplateArgs(errgo.New("first"), map[string]interface{}{"limit": 10}))
+	c.Assert(errgo.TemplateArgs(err), jc.DeepEquals, map[string]interface{}{"limit": 10})
+	err = errgo.Join(errgo.New("first"), errgo.WithTemplateArgs(errgo.New("second"), map[string]interface{}{"user": "jo"}))
+	c.Assert(errgo.TemplateArgs(err), jc.DeepEquals, map[string]interface{}{"user": "jo"})
+
+	e := errgo.NewErr(http.StatusNotFound, "not found")
+	e.SetTemplateArgs(map[string]interface{}{"id": 42})
+	c.Assert(e.TemplateArgs(), jc.DeepEquals, map[string]interface{}{"id": 42})
+}
+
+func (*localizeSuite) TestLocalize(c *gc.C) {
+	quotaErr := errgo.WithTemplateArgs(errgo.Codef(errQuotaExceeded, "used %d of %d", 12, 10), map[string]interface{}{"limit": 10})
+	for i, test := range []struct {
+		message  string
+		err      error
+		tags     []string
+		expected string
+	}{{
+		message:  "exact tag",
+		err:      quotaErr,
+		tags:     []string{"fr"},
+		expected: "Quota de 10 requêtes dépassé",
+	}, {
+		message:  "parent language",
+		err:      quotaErr,
+		tags:     []string{"fr-CA"},
+		expected: "Quota de 10 requêtes dépassé",
+	}, {
+		message:  "tags are matched without regard to case",
+		err:      quotaErr,
+		tags:     []string{"PT-br"},
+		expected: "Cota de 10 solicitações excedida",
+	}, {
+		message:  "first matching preference",
+		err:      quotaErr,
+		tags:     []string{"de", "pt-BR", "fr"},
+		expected: "Cota de 10 solicitações excedida",
+	}, {
+		message:  "no matching tag",
+		err:      quotaErr,
+		tags:     []string{"de", "pt"},
+		expected: "used 12 of 10",
+	}, {
+		message:  "no tags",
+		err:      quotaErr,
+		expected: "used 12 of 10",
+	}, {
+		message:  "http code",
+		err:      errgo.Annotate(errgo.NotFoundf("user %d", 42), "loading"),
+		tags:     []string{"fr"},
+		expected: "Introuvable",
+	}, {
+		message:  "no translation falls back to public message",
+		err:      errgo.WithPublicMessage(errgo.BadRequestf("bad id"), "Invalid request"),
+		tags:     []string{"fr"},
+		expected: "Invalid request",
+	}, {
+		message:  "no translation falls back to redacted message",
+		err:      errgo.Errorf("no user %s", errgo.Sensitive("jo@example.com")),
+		tags:     []string{"fr"},
+		expected: "no user [REDACTED]",
+	}} {
+		c.Logf("%v: %s", i, test.message)
+		c.Check(errgo.Localize(test.err, test.tags...), gc.Equals, test.expected)
+	}
+}
+
+func (*localizeSuite) TestParseAcceptLanguage(c *gc.C) {
+	for i, test := range []struct {
+		header   string
+		expected []string
+	}{{
+		header:   "",
+		expected: nil,
+	}, {
+		header:   "fr",
+		expected: []string{"fr"},
+	}, {
+		header:   "fr-CH, fr;q=0.9, en;q=0.8, de;q=0.7, *;q=0.5",
+		expected: []string{"fr-CH", "fr", "en", "de"},
+	}, {
+		header:   "en;q=0.5, pt-BR, de;q=0, fr;q=bad",
+		expected: []string{"pt-BR", "en"},
+	}, {
+		header:   "en;q=NaN, de;q=1.5, fr;q=-0.5, es;q=Inf, it;q=0.3, pt;q=1",
+		expected: []string{"pt", "it"},
+	}} {
+		c.Logf("%v: %q", i, test.header)
+		c.Check(errgo.ParseAcceptLanguage(test.header), jc.DeepEquals, test.expected)
+	}
+}
+
+func (*localizeSuite) TestHandlerFunc(c *gc.C) {
+	h := errgo.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return errgo.NotFoundf("user %d", 42)
+	})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Language", "de, fr;q=0.5")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	c.Assert(rec.Code, gc.Equals, http.StatusNotFound)
+	c.Assert(rec.Body.String(), gc.Equals, "Introuvable")
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	c.Assert(rec.Body.String(), gc.Equals, "user 42")
+}
+
+func (*localizeSuite) TestWriteLocalizedError(c *gc.C) {
+	err := errgo.NewJSONErrWithCause(fmt.Errorf("raw"), http.StatusNotFound, `{"error":"not found"}`)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Language", "fr-FR")
+	rec := httptest.NewRecorder()
+	errgo.WriteLocalizedError(rec, req, &err)
+	c.Assert(rec.Code, gc.Equals, http.StatusNotFound)
+	c.Assert(rec.Header().Get("Content-Type"), gc.Equals, "text/plain; charset=utf-8")
+	c.Assert(rec.Body.String(), gc.Equals, "Introuvable")
+
+	// WriteError does not translate.
+	rec = httptest.NewRecorder()
+	errgo.WriteError(rec, &err)
+	c.Assert(rec.Body.String(), gc.Equals, `{"error":"not found"}`)
+}
+
+func (*localizeSuite) TestWriteProblem(c *gc.C) {
+	err := errgo.WithTemplateArgs(errgo.Codef(errQuotaExceeded, ""), map[string]interface{}{"limit": 5})
+	req := httptest.NewRequest("GET", "/search", nil)
+	req.Header.Set("Accept-Language", "fr")
+	rec := httptest.NewRecorder()
+	c.Assert(errgo.WriteProblem(rec, req, err), gc.IsNil)
+	var body map[string]interface{}
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &body), gc.IsNil)
+	c.Assert(body["detail"], gc.Equals, "Quota de 5 requêtes dépassé")
+}
+
+func (*localizeSuite) TestJSON(c *gc.C) {
+	err := errgo.WithTemplateArgs(errgo.Codef(errQuotaExceeded, ""), map[string]interface{}{"limit": 5})
+	data, jsonErr := json.Marshal(err)
+	c.Assert(jsonErr, gc.IsNil)
+
+	var obtained errgo.Err
+	c.Assert(json.Unmarshal(data, &obtained), gc.IsNil)
+	c.Assert(errgo.Localize(&obtained, "fr"), gc.Equals, "Quota de 5 requêtes dépassé")
+}
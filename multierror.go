@@ -0,0 +1,122 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// MultiError holds several errors that occurred together, such as the
+// failures of several validated fields or of several backends called
+// concurrently. It is created with Join.
+type MultiError struct {
+	Err
+	errs []error
+}
+
+// Join returns an error holding all the non-nil errors in errs, recording
+// the location of the Join call. It returns nil if there are no such
+// errors.
+//
+// For example:
+//     var errs []error
+//     for _, field := range fields {
+//         errs = append(errs, validate(field))
+//     }
+//     return errgo.Join(errs...)
+func Join(errs ...error) error {
+	m := &MultiError{}
+	for _, err := range errs {
+		if err != nil {
+			m.errs = append(m.errs, err)
+		}
+	}
+	if len(m.errs) == 0 {
+		return nil
+	}
+	m.SetLocation(1)
+	return m
+}
+
+// Errors returns the errors held by m.
+func (m *MultiError) Errors() []error {
+	return m.errs
+}
+
+// Error implements error.Error, joining the messages of the errors held
+// by m with semicolons.
+func (m *MultiError) Error() string {
+	messages := make([]string, len(m.errs))
+	for i, err := range m.errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Message returns the same as Error, so that Details shows the errors
+// held by m.
+func (m *MultiError) Message() string {
+	return m.Error()
+}
+
+// Code returns the most severe HTTP response code of the errors held by
+// m, as returned by the Code function, which is the highest one. Errors
+// without a code count as internal server errors, unless none of the
+// errors has a code, when it returns 0. The Is predicates such as
+// IsNotFound therefore report on the most severe error only.
+func (m *MultiError) Code() int {
+	code := 0
+	uncoded := false
+	for _, err := range m.errs {
+		c := Code(err)
+		if c == 0 {
+			uncoded = true
+		}
+		if c > code {
+			code = c
+		}
+	}
+	if uncoded && code != 0 && code < http.StatusInternalServerError {
+		code = http.StatusInternalServerError
+	}
+	return code
+}
+
+// Unwrap returns the errors held by m, so that the standard library
+// errors.Is and errors.As functions inspect each of them.
+func (m *MultiError) Unwrap() []error {
+	return m.errs
+}
+
+// StackTrace returns the lines of ErrorStack for m.
+func (m *MultiError) StackTrace() []string {
+	return errorStack(m)
+}
+
+// MarshalJSON implements json.Marshaler. The error is written as for
+// Err.MarshalJSON, with the errors held by m nested in its "errors"
+// array.
+func (m *MultiError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newJSONErr(m))
+}
+
+// UnmarshalJSON implements json.Unmarshaler, reading an error written by
+// MarshalJSON. The errors held by m are restored as for Err.UnmarshalJSON.
+func (m *MultiError) UnmarshalJSON(data []byte) error {
+	var j jsonErr
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	m.Err = *j.err()
+	m.errs = j.errors()
+	return nil
+}
+
+type multiError interface {
+	Errors() []error
+}
+
+var _ multiError = (*MultiError)(nil)
@@ -0,0 +1,162 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo_test
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/hifx/errgo"
+)
+
+type multiErrorSuite struct{}
+
+var _ = gc.Suite(&multiErrorSuite{})
+
+func (*multiErrorSuite) TestJoinNil(c *gc.C) {
+	c.Assert(errgo.Join(), gc.IsNil)
+	c.Assert(errgo.Join(nil, nil), gc.IsNil)
+}
+
+func (*multiErrorSuite) TestJoin(c *gc.C) {
+	first := errgo.New("first")
+	second := fmt.Errorf("second")
+	err := errgo.Join(first, nil, second) //err join github.com/hifx/errgo_test.(*multiErrorSuite).TestJoin
+	c.Assert(err.Error(), gc.Equals, "first; second")
+	c.Assert(err.(*errgo.MultiError).Errors(), jc.DeepEquals, []error{first, second})
+	c.Assert(errgo.Cause(err), gc.Equals, err)
+	c.Assert(errgo.Details(err), gc.Equals, replaceLocations("[{$join$: first; second}]"))
+
+	annotated := errgo.Annotate(err, "validating")
+	c.Assert(annotated.Error(), gc.Equals, "validating: first; second")
+	c.Assert(errgo.Cause(annotated), gc.Equals, err)
+}
+
+func (*multiErrorSuite) TestErrorStack(c *gc.C) {
+	first := errgo.New("first")               //err multi-0 (*multiErrorSuite).TestErrorStack
+	second := errgo.Annotate(first, "second") //err multi-1 (*multiErrorSuite).TestErrorStack
+	inner := errgo.Join(io.EOF, second)       //err multi-2 (*multiErrorSuite).TestErrorStack
+	notFound := errgo.NotFoundf("user")       //err multi-3 (*multiErrorSuite).TestErrorStack
+	err := errgo.Join(notFound, inner)        //err multi-4 (*multiErrorSuite).TestErrorStack
+	err = errgo.Trace(err)                    //err multi-5 (*multiErrorSuite).TestErrorStack
+	expected := replaceLocations("" +
+		"$multi-4$: user; EOF; second: first\n" +
+		"\t$multi-3$: user\n" +
+		"\t$multi-2$: EOF; second: first\n" +
+		"\t\tEOF\n" +
+		"\t\t$multi-0$: first\n" +
+		"\t\t$multi-1$: second\n" +
+		"$multi-5$: ")
+	c.Assert(errgo.ErrorStack(err), gc.Equals, expected)
+	multi := errgo.Cause(err).(*errgo.MultiError)
+	c.Assert(multi.StackTrace(), jc.DeepEquals, strings.Split(expected, "\n")[:6])
+}
+
+func (*multiErrorSuite) TestCode(c *gc.C) {
+	for i, test := range []struct {
+		message string
+		errs    []error
+		code    int
+	}{{
+		message: "no codes",
+		errs:    []error{io.EOF, errgo.New("first")},
+		code:    0,
+	}, {
+		message: "single code",
+		errs:    []error{errgo.Annotate(errgo.NotFoundf("user"), "loading")},
+		code:    http.StatusNotFound,
+	}, {
+		message: "errors without a code are internal server errors",
+		errs:    []error{io.EOF, errgo.Annotate(errgo.NotFoundf("user"), "loading")},
+		code:    http.StatusInternalServerError,
+	}, {
+		message: "more severe than errors without a code",
+		errs:    []error{io.EOF, errgo.ServiceUnavailablef("db")},
+		code:    http.StatusServiceUnavailable,
+	}, {
+		message: "most severe code",
+		errs:    []error{errgo.BadRequestf("name"), errgo.ServiceUnavailablef("db"), errgo.NotFoundf("user")},
+		code:    http.StatusServiceUnavailable,
+	}, {
+		message: "nested",
+		errs:    []error{errgo.BadRequestf("name"), errgo.Join(errgo.Conflictf("email"))},
+		code:    http.StatusConflict,
+	}} {
+		c.Logf("%v: %s", i, test.message)
+		err := errgo.Join(test.errs...)
+		c.Check(err.(*errgo.MultiError).Code(), gc.Equals, test.code)
+		c.Check(errgo.Code(err), gc.Equals, test.code)
+		c.Check(errgo.Code(errgo.Annotate(err, "annotated")), gc.Equals, test.code)
+	}
+}
+
+func (*multiErrorSuite) TestSatisfiers(c *gc.C) {
+	err := errgo.Join(errgo.BadRequestf("name"), errgo.Annotate(errgo.NotFoundf("user"), "loading"))
+	c.Assert(errgo.IsNotFound(err), jc.IsTrue)
+	c.Assert(errgo.IsBadRequest(err), jc.IsFalse)
+	c.Assert(errgo.IsNotFound(errgo.Trace(err)), jc.IsTrue)
+	c.Assert(errgo.NewProblem(err).Status, gc.Equals, http.StatusNotFound)
+}
+
+func (*multiErrorSuite) TestStandardErrors(c *gc.C) {
+	first := errgo.New("first")
+	err := errgo.Trace(errgo.Join(errgo.Annotate(io.EOF, "reading"), first))
+	c.Assert(stderrors.Is(err, io.EOF), jc.IsTrue)
+	c.Assert(stderrors.Is(err, first), jc.IsTrue)
+	c.Assert(stderrors.Is(err, io.ErrUnexpectedEOF), jc.IsFalse)
+
+	var multi *errgo.MultiError
+	c.Assert(stderrors.As(err, &multi), jc.IsTrue)
+	c.Assert(multi.Errors(), gc.HasLen, 2)
+}
+
+func (*multiErrorSuite) TestLookups(c *gc.C) {
+	err := errgo.Join(
+		io.EOF,
+		errgo.Codef(errCardDeclined, "card declined"),
+		errgo.WithPublicMessage(errgo.New("no rows"), "Resource not found"),
+		errgo.WithRetryAfter(errgo.New("busy"), time.Minute),
+	)
+	for _, err := range []error{err, errgo.Trace(err)} {
+		c.Check(errgo.ErrorCodeOf(err), gc.Equals, errCardDeclined)
+		c.Check(errgo.HasCode(err, errCardDeclined), jc.IsTrue)
+		c.Check(errgo.PublicMessage(err), gc.Equals, "Resource not found")
+		c.Check(errgo.RetryAfter(err), gc.Equals, time.Minute)
+	}
+}
+
+func (*multiErrorSuite) TestMarshalJSON(c *gc.C) {
+	err := errgo.Join(errgo.NotFoundf("user"), errgo.Join(io.EOF, errgo.New("first")))
+	data, jsonErr := json.Marshal(err)
+	c.Assert(jsonErr, gc.IsNil)
+	var obtained errgo.Err
+	c.Assert(json.Unmarshal(data, &obtained), gc.IsNil)
+	c.Assert(obtained.Error(), gc.Equals, "user; EOF; first")
+	c.Assert(obtained.Code(), gc.Equals, http.StatusInternalServerError)
+
+	var multi errgo.MultiError
+	c.Assert(json.Unmarshal(data, &multi), gc.IsNil)
+	c.Assert(multi.Error(), gc.Equals, "user; EOF; first")
+	c.Assert(multi.Code(), gc.Equals, http.StatusInternalServerError)
+	c.Assert(multi.Errors(), gc.HasLen, 2)
+	c.Assert(errgo.IsNotFound(multi.Errors()[0]), jc.IsTrue)
+	inner, ok := multi.Errors()[1].(*errgo.MultiError)
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(inner.Errors(), gc.HasLen, 2)
+	c.Assert(errgo.ErrorStack(&multi), gc.Equals, errgo.ErrorStack(err))
+
+	annotated := errgo.Annotate(err, "validating")
+	data, jsonErr = json.Marshal(annotated)
+	c.Assert(jsonErr, gc.IsNil)
+	c.Assert(json.Unmarshal(data, &obtained), gc.IsNil)
+	c.Assert(errgo.ErrorStack(&obtained), gc.Equals, errgo.ErrorStack(annotated))
+}
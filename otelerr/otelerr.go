@@ -0,0 +1,115 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+// Package otelerr records errgo errors on OpenTelemetry spans, so that
+// traces carry the same error stacks, codes and fields as logs.
+package otelerr
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/hifx/errgo"
+)
+
+// The keys of the attributes added to exception events for errgo errors.
+const (
+	// CodeKey holds the HTTP response code of the error, as returned by
+	// errgo.Code.
+	CodeKey = attribute.Key("errgo.code")
+
+	// ErrorCodeKey holds the application error code of the error, as
+	// returned by errgo.ErrorCodeOf.
+	ErrorCodeKey = attribute.Key("errgo.error_code")
+
+	// FieldKeyPrefix prefixes the keys of the fields of the error, as
+	// returned by errgo.SafeFields.
+	FieldKeyPrefix = "errgo.field."
+)
+
+// RecordError records err on span, doing nothing if err is nil. The span
+// status is set to Error, with the message of err, and an exception event
+// is added. The event holds the type of the Cause of err, its message, its
+// error stack as the stack trace, and its HTTP response code, application
+// error code and fields as the attributes named by CodeKey, ErrorCodeKey
+// and FieldKeyPrefix. Messages, the stack trace and fields have sensitive
+// data redacted, as by errgo.SafeError, errgo.SafeErrorStack and
+// errgo.SafeFields. Any options are applied to the event after these
+// attributes.
+func RecordError(span trace.Span, err error, opts ...trace.EventOption) {
+	if err == nil || !span.IsRecording() {
+		return
+	}
+	message := errgo.SafeError(err)
+	span.SetStatus(codes.Error, message)
+	opts = append([]trace.EventOption{trace.WithAttributes(Attributes(err)...)}, opts...)
+	span.AddEvent(semconv.ExceptionEventName, opts...)
+}
+
+// Attributes returns the attributes of the exception event recorded for
+// err by RecordError.
+func Attributes(err error) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.ExceptionType(fmt.Sprintf("%T", errgo.Cause(err))),
+		semconv.ExceptionMessage(errgo.SafeError(err)),
+		semconv.ExceptionStacktrace(errgo.SafeErrorStack(err)),
+	}
+	if code := errgo.Code(err); code != 0 {
+		attrs = append(attrs, CodeKey.Int(code))
+	}
+	if c := errgo.ErrorCodeOf(err); c != nil {
+		attrs = append(attrs, ErrorCodeKey.String(c.Code))
+	}
+	for _, field := range errgo.SafeFields(err) {
+		attrs = append(attrs, fieldAttribute(field))
+	}
+	return attrs
+}
+
+// fieldAttribute returns the attribute for field, keeping the type of its
+// value where an attribute can hold it.
+func fieldAttribute(field errgo.Field) attribute.KeyValue {
+	key := attribute.Key(FieldKeyPrefix + field.Key)
+	switch value := field.Value.(type) {
+	case string:
+		return key.String(value)
+	case bool:
+		return key.Bool(value)
+	case int:
+		return key.Int(value)
+	case int64:
+		return key.Int64(value)
+	case float64:
+		return key.Float64(value)
+	}
+	return key.String(fmt.Sprint(field.Value))
+}
+
+// RecordErrorCtx records err on the span held by ctx, as for RecordError.
+func RecordErrorCtx(ctx context.Context, err error, opts ...trace.EventOption) {
+	RecordError(trace.SpanFromContext(ctx), err, opts...)
+}
+
+// RegisterContextValues registers the trace and span IDs of the span held
+// by a context as the errgo.CtxTraceID and errgo.CtxSpanID context values,
+// so that they are recorded by errgo.NewCtx, errgo.AnnotateCtx and
+// errgo.TraceCtx.
+func RegisterContextValues() {
+	errgo.RegisterContextValue(errgo.CtxTraceID, func(ctx context.Context) string {
+		if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+			return sc.TraceID().String()
+		}
+		return ""
+	})
+	errgo.RegisterContextValue(errgo.CtxSpanID, func(ctx context.Context) string {
+		if sc := trace.SpanContextFromContext(ctx); sc.HasSpanID() {
+			return sc.SpanID().String()
+		}
+		return ""
+	})
+}
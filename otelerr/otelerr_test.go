@@ -0,0 +1,143 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package otelerr_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	jc "github.com/juju/testing/checkers"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	gc "gopkg.in/check.v1"
+
+	"github.com/hifx/errgo"
+	"github.com/hifx/errgo/otelerr"
+)
+
+func Test(t *testing.T) {
+	gc.TestingT(t)
+}
+
+type otelerrSuite struct {
+	exporter *tracetest.InMemoryExporter
+	provider *sdktrace.TracerProvider
+}
+
+var _ = gc.Suite(&otelerrSuite{})
+
+func (s *otelerrSuite) SetUpTest(c *gc.C) {
+	s.exporter = tracetest.NewInMemoryExporter()
+	s.provider = sdktrace.NewTracerProvider(sdktrace.WithSyncer(s.exporter))
+}
+
+func (s *otelerrSuite) TearDownTest(c *gc.C) {
+	c.Assert(s.provider.Shutdown(context.Background()), gc.IsNil)
+}
+
+// record records err on a new span, returning the exported span.
+func (s *otelerrSuite) record(c *gc.C, err error) tracetest.SpanStub {
+	_, span := s.provider.Tracer("test").Start(context.Background(), "operation")
+	otelerr.RecordError(span, err)
+	span.End()
+	spans := s.exporter.GetSpans()
+	c.Assert(spans, gc.HasLen, 1)
+	return spans[0]
+}
+
+func attributeMap(attrs []attribute.KeyValue) map[attribute.Key]interface{} {
+	m := make(map[attribute.Key]interface{})
+	for _, attr := range attrs {
+		m[attr.Key] = attr.Value.AsInterface()
+	}
+	return m
+}
+
+func (s *otelerrSuite) TestRecordError(c *gc.C) {
+	err := errgo.New("first")
+	err = errgo.AnnotateWith(err, "loading", errgo.F("user", 42), errgo.F("path", "/users/42"), errgo.F("token", errgo.Sensitive("s3cr3t")))
+	err = errgo.Wrap(err, errgo.NotFoundf("user %s", errgo.Sensitive("jo@example.com")))
+	span := s.record(c, err)
+
+	c.Assert(span.Status.Code, gc.Equals, codes.Error)
+	c.Assert(span.Status.Description, gc.Equals, "user [REDACTED]")
+	c.Assert(span.Events, gc.HasLen, 1)
+	event := span.Events[0]
+	c.Assert(event.Name, gc.Equals, "exception")
+	c.Assert(attributeMap(event.Attributes), jc.DeepEquals, map[attribute.Key]interface{}{
+		"exception.type":       "*errgo.Err",
+		"exception.message":    "user [REDACTED]",
+		"exception.stacktrace": errgo.SafeErrorStack(err),
+		"errgo.code":           int64(404),
+		"errgo.field.user":     int64(42),
+		"errgo.field.path":     "/users/42",
+		"errgo.field.token":    "[REDACTED]",
+	})
+	stack := attributeMap(event.Attributes)["exception.stacktrace"].(string)
+	c.Assert(stack, jc.Contains, "loading user=42 path=/users/42 token=[REDACTED]")
+	c.Assert(stack, jc.Contains, "user [REDACTED]")
+	c.Assert(stack, gc.Not(jc.Contains), "jo@example.com")
+	c.Assert(stack, gc.Not(jc.Contains), "s3cr3t")
+}
+
+func (s *otelerrSuite) TestRecordPlainError(c *gc.C) {
+	span := s.record(c, fmt.Errorf("raw"))
+	c.Assert(span.Status.Code, gc.Equals, codes.Error)
+	c.Assert(attributeMap(span.Events[0].Attributes), jc.DeepEquals, map[attribute.Key]interface{}{
+		"exception.type":       "*errors.errorString",
+		"exception.message":    "raw",
+		"exception.stacktrace": "raw",
+	})
+}
+
+var errQuotaExceeded = errgo.MustRegisterCode(errgo.ErrorCode{
+	Code:    "OTEL_QUOTA_EXCEEDED",
+	Status:  429,
+	Message: "quota exceeded",
+})
+
+func (s *otelerrSuite) TestRecordErrorCode(c *gc.C) {
+	span := s.record(c, errgo.Codef(errQuotaExceeded, ""))
+	attrs := attributeMap(span.Events[0].Attributes)
+	c.Assert(attrs["errgo.error_code"], gc.Equals, "OTEL_QUOTA_EXCEEDED")
+	c.Assert(attrs["errgo.code"], gc.Equals, int64(429))
+}
+
+func (s *otelerrSuite) TestRecordNil(c *gc.C) {
+	span := s.record(c, nil)
+	c.Assert(span.Status.Code, gc.Equals, codes.Unset)
+	c.Assert(span.Events, gc.HasLen, 0)
+}
+
+func (s *otelerrSuite) TestRecordErrorOptions(c *gc.C) {
+	_, span := s.provider.Tracer("test").Start(context.Background(), "operation")
+	otelerr.RecordError(span, errgo.New("first"), trace.WithAttributes(attribute.String("extra", "value")))
+	span.End()
+	attrs := attributeMap(s.exporter.GetSpans()[0].Events[0].Attributes)
+	c.Assert(attrs["extra"], gc.Equals, "value")
+	c.Assert(attrs["exception.message"], gc.Equals, "first")
+}
+
+func (s *otelerrSuite) TestRecordErrorCtx(c *gc.C) {
+	ctx, span := s.provider.Tracer("test").Start(context.Background(), "operation")
+	otelerr.RecordErrorCtx(ctx, errgo.New("first"))
+	span.End()
+	c.Assert(s.exporter.GetSpans()[0].Events, gc.HasLen, 1)
+}
+
+func (s *otelerrSuite) TestRegisterContextValues(c *gc.C) {
+	otelerr.RegisterContextValues()
+	ctx, span := s.provider.Tracer("test").Start(context.Background(), "operation")
+	defer span.End()
+	err := errgo.NewCtx(ctx, "first")
+	c.Assert(errgo.TraceID(err), gc.Equals, span.SpanContext().TraceID().String())
+	c.Assert(errgo.SpanID(err), gc.Equals, span.SpanContext().SpanID().String())
+
+	err = errgo.NewCtx(context.Background(), "first")
+	c.Assert(errgo.TraceID(err), gc.Equals, "")
+}
@@ -99,4 +99,13 @@ func setLocationsForErrorTags(filename string) {
 func init() {
 	setLocationsForErrorTags("error_test.go")
 	setLocationsForErrorTags("functions_test.go")
+	setLocationsForErrorTags("http_test.go")
+	setLocationsForErrorTags("panic_test.go")
+	setLocationsForErrorTags("multierror_test.go")
+	setLocationsForErrorTags("fields_test.go")
+	setLocationsForErrorTags("slog_test.go")
+	setLocationsForErrorTags("format_test.go")
+	setLocationsForErrorTags("retry_test.go")
+	setLocationsForErrorTags("parse_test.go")
+	setLocationsForErrorTags("redact_test.go")
 }
@@ -0,0 +1,113 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+)
+
+// Recover recovers any panic in the function which defers it, setting
+// *err to an error which satisfies IsInternalServer(). The error wraps the
+// panic value if it is an error, and otherwise holds its formatted value.
+// Its location is where the panic occurred, and it carries the stack of
+// the panicking goroutine, which ErrorStack shows whether or not stack
+// capture is enabled. Recover must be deferred directly, as recover only
+// works in the deferred function itself.
+//
+// For example:
+//
+//    func Frombulate() (err error) {
+//        defer errgo.Recover(&err)
+//        ...
+//    }
+//
+func Recover(err *error) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	*err = newPanicError(v)
+}
+
+// Go calls fn in a new goroutine, returning a channel which receives the
+// error returned by fn, if any, and is then closed. A panic in fn is
+// recovered as for Recover, and annotated with the location of the call
+// to Go, which is otherwise missing from the stack of the goroutine.
+//
+// For example:
+//
+//    errc := errgo.Go(func() error {
+//        return process(job)
+//    })
+//    ...
+//    if err := <-errc; err != nil {
+//        log.Print(errgo.ErrorStack(err))
+//    }
+//
+func Go(fn func() error) <-chan error {
+	site := &Err{message: "goroutine"}
+	site.SetLocation(1)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		if err := goCall(fn, site); err != nil {
+			errc <- err
+		}
+	}()
+	return errc
+}
+
+// goCall calls fn for Go, returning any panic as an error annotated by
+// site.
+func goCall(fn func() error, site *Err) (err error) {
+	defer func() {
+		v := recover()
+		if v == nil {
+			return
+		}
+		panicErr := newPanicError(v)
+		site.previous = panicErr
+		site.cause = Cause(panicErr)
+		err = site
+	}()
+	return fn()
+}
+
+// newPanicError returns an error which satisfies IsInternalServer() for the
+// panic value v, carrying the stack of the panicking goroutine. It must be
+// called by the deferred function that recovered the panic.
+func newPanicError(v interface{}) *Err {
+	err, ok := v.(error)
+	if !ok {
+		err = fmt.Errorf("%v", v)
+	}
+	e := &Err{
+		message:  "panic",
+		previous: err,
+		code:     http.StatusInternalServerError,
+	}
+	// Skip runtime.Callers, newPanicError and the deferred function, which
+	// leaves the runtime panic frames followed by the panicking code.
+	var pcs [maxStackDepth]uintptr
+	n := runtime.Callers(3, pcs[:])
+	e.pcs = append([]uintptr(nil), pcs[:n]...)
+	// The location is the first frame outside the runtime.
+	frames := runtime.CallersFrames(e.pcs)
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "runtime.") {
+			e.function = frame.Function
+			e.file = trimSourcePath(frame.File, frame.Function)
+			e.line = frame.Line
+			break
+		}
+		if !more {
+			break
+		}
+	}
+	return e
+}
@@ -0,0 +1,111 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/hifx/errgo"
+)
+
+type panicSuite struct{}
+
+var _ = gc.Suite(&panicSuite{})
+
+func panicker(v interface{}) {
+	panic(v) //err panicker panicker
+}
+
+// recovering calls f, returning any panic as recovered by
+// Recover.
+func recovering(f func()) (err error) {
+	defer errgo.Recover(&err)
+	f()
+	return nil
+}
+
+func (*panicSuite) TestRecover(c *gc.C) {
+	cause := fmt.Errorf("boom")
+	err := recovering(func() { panicker(cause) })
+	c.Assert(err, gc.NotNil)
+	c.Assert(err.Error(), gc.Equals, "panic: boom")
+	c.Assert(errgo.IsInternalServer(err), jc.IsTrue)
+	c.Assert(err.(*errgo.Err).Underlying(), gc.Equals, cause)
+	c.Assert(errors.Is(err, cause), jc.IsTrue)
+
+	file, function, line := err.(*errgo.Err).Location()
+	c.Assert(file, gc.Equals, location("panicker").file)
+	c.Assert(line, gc.Equals, location("panicker").line)
+	c.Assert(function, gc.Equals, "github.com/hifx/errgo_test.panicker")
+
+	frames := err.(*errgo.Err).StackFrames()
+	c.Assert(len(frames) > 1, jc.IsTrue)
+	var found bool
+	for _, frame := range frames {
+		if frame.Function == "github.com/hifx/errgo_test.panicker" {
+			found = true
+		}
+	}
+	c.Assert(found, jc.IsTrue)
+	c.Assert(strings.Contains(errgo.ErrorStack(err), "\tgithub.com/hifx/errgo/panic_test.go:"), jc.IsTrue)
+
+	err = recovering(func() { panicker(42) })
+	c.Assert(err.Error(), gc.Equals, "panic: 42")
+	c.Assert(recovering(func() {}), gc.IsNil)
+}
+
+func (*panicSuite) TestRecoverReplacesError(c *gc.C) {
+	f := func() (err error) {
+		defer errgo.Recover(&err)
+		defer func() {
+			err = errgo.New("ignored")
+		}()
+		panicker("boom")
+		return nil
+	}
+	err := f()
+	c.Assert(err, gc.ErrorMatches, "panic: boom")
+}
+
+func (*panicSuite) TestGo(c *gc.C) {
+	errc := errgo.Go(func() error {
+		return nil
+	})
+	err, ok := <-errc
+	c.Assert(err, gc.IsNil)
+	c.Assert(ok, jc.IsFalse)
+
+	first := errgo.New("first")
+	errc = errgo.Go(func() error {
+		return first
+	})
+	c.Assert(<-errc, gc.Equals, first)
+	_, ok = <-errc
+	c.Assert(ok, jc.IsFalse)
+}
+
+func (*panicSuite) TestGoPanic(c *gc.C) {
+	cause := fmt.Errorf("boom")
+	errc := errgo.Go(func() error { //err go
+		panicker(cause)
+		return nil
+	})
+	err := <-errc
+	c.Assert(err, gc.ErrorMatches, "goroutine: panic: boom")
+	c.Assert(errgo.IsInternalServer(err), jc.IsTrue)
+	c.Assert(errors.Is(err, cause), jc.IsTrue)
+
+	file, _, line := err.(*errgo.Err).Location()
+	c.Assert(file, gc.Equals, location("go").file)
+	c.Assert(line, gc.Equals, location("go").line)
+
+	stack := errgo.ErrorStack(err)
+	c.Assert(stack, jc.Contains, fmt.Sprintf("%s:%d panicker: panic", location("panicker").file, location("panicker").line))
+	c.Assert(stack, jc.Contains, fmt.Sprintf("%s:%d (*panicSuite).TestGoPanic: goroutine", location("go").file, location("go").line))
+}
@@ -0,0 +1,218 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// StackEntry holds an entry of an error stack reconstructed from its text
+// by ParseErrorStack or ParseDetails.
+type StackEntry struct {
+	// File, Line and Function hold the location of the entry, if it had
+	// one. Entries without a location are errors from outside errgo,
+	// such as io.EOF.
+	File     string
+	Line     int
+	Function string
+
+	// Message holds the text of the entry following its location: its
+	// message, followed by any fields as key=value pairs and, in the text
+	// of ErrorStack, by the text of any cause set by Wrap or Wrapf, as
+	// the text does not delimit it.
+	Message string
+
+	// Cause holds the message of the original error of the stack the
+	// entry belongs to, which is the first entry of the stack. A cause
+	// set by Wrap or Wrapf is left in Message, as the text does not
+	// delimit it.
+	Cause string
+
+	// Frames holds the call stack captured for the entry, as shown by
+	// ErrorStack. The function names are those shown, without their
+	// package paths.
+	Frames []Frame
+
+	// Errors holds the entries of the error stacks of the errors held
+	// by a MultiError, as shown by ErrorStack, one after the other. As
+	// the stacks are not delimited, a line without a location is taken
+	// to start the next of them.
+	Errors []StackEntry
+}
+
+// HasLocation reports whether the entry has a location.
+func (e StackEntry) HasLocation() bool {
+	return e.File != ""
+}
+
+// entryLocation matches the location which starts an entry of an error
+// stack, giving its file, line and function and the rest of the text.
+// Files are always Go source files, so messages which themselves hold
+// colons are not mistaken for locations.
+var entryLocation = regexp.MustCompile(`^(\S+?\.go):(\d+) (\S*): ?`)
+
+// frameLocation matches a frame of a call stack shown by ErrorStack.
+var frameLocation = regexp.MustCompile(`^(\S+?\.go):(\d+) (\S*)$`)
+
+// parseLocation returns the entry started by text, if it starts with a
+// location, and the length of the location.
+func parseLocation(text string) (StackEntry, int, bool) {
+	m := entryLocation.FindStringSubmatchIndex(text)
+	if m == nil {
+		return StackEntry{}, 0, false
+	}
+	line, err := strconv.Atoi(text[m[4]:m[5]])
+	if err != nil {
+		return StackEntry{}, 0, false
+	}
+	return StackEntry{
+		File:     text[m[2]:m[3]],
+		Line:     line,
+		Function: text[m[6]:m[7]],
+	}, m[1], true
+}
+
+// ParseErrorStack reconstructs the entries of an error stack from its
+// text, as returned by ErrorStack, original error first. Messages may
+// hold colons, braces and new lines. Lines of captured call stacks and of
+// the stacks of the errors held by a MultiError are attached to the entry
+// they follow.
+func ParseErrorStack(text string) ([]StackEntry, error) {
+	return parseErrorStack(text, false)
+}
+
+// parseErrorStack is the implementation of ParseErrorStack. Lines without
+// a location continue the previous message unless nested is true, when
+// they start an entry.
+func parseErrorStack(text string, nested bool) ([]StackEntry, error) {
+	if text == "" {
+		return nil, nil
+	}
+	var entries []StackEntry
+	// indented holds the indented lines following the last entry.
+	var indented []string
+	flush := func() error {
+		if len(indented) == 0 {
+			return nil
+		}
+		last := &entries[len(entries)-1]
+		lines := indented
+		indented = nil
+		for len(lines) > 0 && !strings.HasSuffix(lines[0], ":") {
+			m := frameLocation.FindStringSubmatch(lines[0])
+			if m == nil {
+				break
+			}
+			line, _ := strconv.Atoi(m[2])
+			last.Frames = append(last.Frames, Frame{File: m[1], Line: line, Function: m[3]})
+			lines = lines[1:]
+		}
+		if len(lines) == 0 {
+			return nil
+		}
+		errs, err := parseErrorStack(strings.Join(lines, "\n"), true)
+		if err != nil {
+			return Trace(err)
+		}
+		last.Errors = errs
+		return nil
+	}
+	for i, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, "\t") {
+			if i == 0 {
+				return nil, Errorf("cannot parse error stack: unexpected indented first line %q", line)
+			}
+			indented = append(indented, line[1:])
+			continue
+		}
+		entry, n, ok := parseLocation(line)
+		if !ok && i > 0 && !nested {
+			// A line which is neither indented nor starts with a
+			// location continues the message of the previous line.
+			if len(indented) > 0 {
+				indented[len(indented)-1] += "\n" + line
+			} else {
+				entries[len(entries)-1].Message += "\n" + line
+			}
+			continue
+		}
+		if err := flush(); err != nil {
+			return nil, Trace(err)
+		}
+		entry.Message = line[n:]
+		entries = append(entries, entry)
+	}
+	if err := flush(); err != nil {
+		return nil, Trace(err)
+	}
+	var cause string
+	for i := range entries {
+		// Each entry without a location starts one of the stacks held
+		// by a MultiError.
+		if i == 0 || nested && !entries[i].HasLocation() {
+			cause = entries[i].Message
+		}
+		entries[i].Cause = cause
+	}
+	return entries, nil
+}
+
+// ParseDetails reconstructs the entries of an error stack from its text,
+// as returned by Details, most recent entry first. Messages may hold
+// colons and braces: an entry only ends where the next one starts with a
+// location, or where the error stack ends in an error without a location.
+// A message in the last entry with a location which holds "} {" is taken
+// to be followed by such an error.
+func ParseDetails(text string) ([]StackEntry, error) {
+	if text == "[]" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(text, "[{") || !strings.HasSuffix(text, "}]") {
+		return nil, Errorf("cannot parse details %q: not enclosed in [{ }]", text)
+	}
+	text = text[2 : len(text)-2]
+	var entries []StackEntry
+	for {
+		entry, n, located := parseLocation(text)
+		rest := text[n:]
+		// Find the start of the next entry with a location.
+		end := -1
+		for i := strings.Index(rest, "} {"); i >= 0; {
+			if _, _, ok := parseLocation(rest[i+3:]); ok {
+				end = i
+				break
+			}
+			j := strings.Index(rest[i+1:], "} {")
+			if j < 0 {
+				break
+			}
+			i += j + 1
+		}
+		if end < 0 {
+			entry.Message = rest
+			if located {
+				// The last entry with a location may be followed by
+				// an error without one.
+				if i := strings.LastIndex(rest, "} {"); i >= 0 {
+					entry.Message = rest[:i]
+					entries = append(entries, entry)
+					entry = StackEntry{Message: rest[i+3:]}
+				}
+			}
+			entries = append(entries, entry)
+			break
+		}
+		entry.Message = rest[:end]
+		entries = append(entries, entry)
+		text = rest[end+3:]
+	}
+	// The original error comes last.
+	cause := entries[len(entries)-1].Message
+	for i := range entries {
+		entries[i].Cause = cause
+	}
+	return entries, nil
+}
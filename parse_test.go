@@ -0,0 +1,240 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo_test
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/hifx/errgo"
+)
+
+type parseSuite struct{}
+
+var _ = gc.Suite(&parseSuite{})
+
+// entryAt returns the entry expected for the location of tag.
+func entryAt(tag, function, message, cause string) errgo.StackEntry {
+	loc := location(tag)
+	return errgo.StackEntry{
+		File:     loc.file,
+		Line:     loc.line,
+		Function: function,
+		Message:  message,
+		Cause:    cause,
+	}
+}
+
+// stackText returns the entries as written by ErrorStack.
+func stackText(entries []errgo.StackEntry) string {
+	var lines []string
+	for _, entry := range entries {
+		line := entry.Message
+		if entry.HasLocation() {
+			line = fmt.Sprintf("%s:%d %s: %s", entry.File, entry.Line, entry.Function, entry.Message)
+		}
+		lines = append(lines, line)
+		for _, frame := range entry.Frames {
+			lines = append(lines, fmt.Sprintf("\t%s:%d %s", frame.File, frame.Line, frame.Function))
+		}
+		if entry.Errors != nil {
+			for _, line := range strings.Split(stackText(entry.Errors), "\n") {
+				lines = append(lines, "\t"+line)
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// detailsText returns the entries as written by Details.
+func detailsText(entries []errgo.StackEntry) string {
+	var parts []string
+	for _, entry := range entries {
+		part := entry.Message
+		if entry.HasLocation() {
+			part = fmt.Sprintf("%s:%d %s: %s", entry.File, entry.Line, entry.Function, entry.Message)
+		}
+		parts = append(parts, "{"+part+"}")
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+// checkParseErrorStack checks that text, as returned by ErrorStack, is
+// written the same way again once parsed.
+func checkParseErrorStack(c *gc.C, text string) {
+	entries, err := errgo.ParseErrorStack(text)
+	c.Check(err, gc.IsNil)
+	c.Check(stackText(entries), gc.Equals, text)
+}
+
+// checkParseDetails checks that text, as returned by Details, is written
+// the same way again once parsed.
+func checkParseDetails(c *gc.C, text string) {
+	entries, err := errgo.ParseDetails(text)
+	c.Check(err, gc.IsNil)
+	c.Check(detailsText(entries), gc.Equals, text)
+}
+
+func (*parseSuite) TestParseErrorStack(c *gc.C) {
+	err := errgo.New("first: {a} b")                                             //err parseFirst
+	err = errgo.AnnotateWith(err, "loading {user}: failed", errgo.F("user", 42)) //err parseAnnotate
+	err = errgo.Trace(err)                                                       //err parseTrace
+	err = errgo.Wrap(err, io.EOF)                                                //err parseWrap
+	err = errgo.Annotate(err, "line one\nline two: x")                           //err parseMultiline
+	text := errgo.ErrorStack(err)
+	entries, parseErr := errgo.ParseErrorStack(text)
+	c.Assert(parseErr, gc.IsNil)
+	function := "(*parseSuite).TestParseErrorStack"
+	c.Assert(entries, jc.DeepEquals, []errgo.StackEntry{
+		entryAt("parseFirst", function, "first: {a} b", "first: {a} b"),
+		entryAt("parseAnnotate", function, "loading {user}: failed user=42", "first: {a} b"),
+		entryAt("parseTrace", function, "", "first: {a} b"),
+		entryAt("parseWrap", function, "EOF", "first: {a} b"),
+		entryAt("parseMultiline", function, "line one\nline two: x", "first: {a} b"),
+	})
+	c.Assert(stackText(entries), gc.Equals, text)
+}
+
+func (*parseSuite) TestParseErrorStackWithoutLocation(c *gc.C) {
+	err := errgo.Annotate(io.EOF, "reading {header}: ") //err parseForeign
+	text := errgo.ErrorStack(err)
+	entries, parseErr := errgo.ParseErrorStack(text)
+	c.Assert(parseErr, gc.IsNil)
+	c.Assert(entries, jc.DeepEquals, []errgo.StackEntry{
+		{Message: "EOF", Cause: "EOF"},
+		entryAt("parseForeign", "(*parseSuite).TestParseErrorStackWithoutLocation", "reading {header}: ", "EOF"),
+	})
+	c.Assert(entries[0].HasLocation(), jc.IsFalse)
+	c.Assert(stackText(entries), gc.Equals, text)
+
+	entries, parseErr = errgo.ParseErrorStack("EOF")
+	c.Assert(parseErr, gc.IsNil)
+	c.Assert(entries, jc.DeepEquals, []errgo.StackEntry{{Message: "EOF", Cause: "EOF"}})
+}
+
+func (*parseSuite) TestParseErrorStackFrames(c *gc.C) {
+	defer errgo.SetStackCapture(errgo.StackCapture())
+	errgo.SetStackCapture(true)
+	err := errgo.Annotate(errgo.New("first"), "second")
+	text := errgo.ErrorStack(err)
+	entries, parseErr := errgo.ParseErrorStack(text)
+	c.Assert(parseErr, gc.IsNil)
+	c.Assert(entries, gc.HasLen, 2)
+	c.Assert(entries[0].Message, gc.Equals, "first")
+	c.Assert(entries[0].Frames, gc.Not(gc.HasLen), 0)
+	c.Assert(entries[0].Frames[0].Function, gc.Equals, "(*parseSuite).TestParseErrorStackFrames")
+	c.Assert(entries[0].Errors, gc.IsNil)
+	c.Assert(entries[1].Message, gc.Equals, "second")
+	c.Assert(entries[1].Frames, gc.IsNil)
+	c.Assert(stackText(entries), gc.Equals, text)
+}
+
+func (*parseSuite) TestParseErrorStackMultiError(c *gc.C) {
+	err := errgo.Join(
+		errgo.New("first"),
+		errgo.Annotate(io.EOF, "second: {x}"),
+	)
+	err = errgo.Annotate(err, "both")
+	text := errgo.ErrorStack(err)
+	entries, parseErr := errgo.ParseErrorStack(text)
+	c.Assert(parseErr, gc.IsNil)
+	c.Assert(entries, gc.HasLen, 2)
+	var messages, causes []string
+	for _, entry := range entries[0].Errors {
+		messages = append(messages, entry.Message)
+		causes = append(causes, entry.Cause)
+	}
+	c.Assert(messages, jc.DeepEquals, []string{"first", "EOF", "second: {x}"})
+	c.Assert(causes, jc.DeepEquals, []string{"first", "EOF", "EOF"})
+	c.Assert(entries[1].Message, gc.Equals, "both")
+	c.Assert(stackText(entries), gc.Equals, text)
+}
+
+func (*parseSuite) TestParseRoundTrip(c *gc.C) {
+	for i, test := range []struct {
+		message string
+		err     error
+	}{{
+		message: "nil",
+	}, {
+		message: "raw error",
+		err:     fmt.Errorf("raw"),
+	}, {
+		message: "annotated error",
+		err:     errgo.Annotate(errgo.New("first error"), "annotation"),
+	}, {
+		message: "wrapped error",
+		err:     errgo.Wrap(errgo.New("first error"), newError("detailed error")),
+	}, {
+		message: "annotated wrapped error",
+		err:     errgo.Annotatef(errgo.Wrapf(errgo.Errorf("first error"), io.EOF, "value %d", 42), "annotated"),
+	}, {
+		message: "traced, masked and annotated",
+		err:     errgo.Trace(errgo.Annotate(errgo.Maskf(errgo.Trace(newNonComparableError("first error")), "masked"), "more: {context}")),
+	}, {
+		message: "annotated with fields",
+		err:     errgo.AnnotateWith(errgo.NotFoundf("user %d", 42), "loading} {user", errgo.F("id", "a b")),
+	}, {
+		message: "multiple errors",
+		err:     errgo.Annotate(errgo.Join(errgo.New("first"), errgo.Annotate(io.EOF, "second")), "both"),
+	}} {
+		c.Logf("%v: %s", i, test.message)
+		checkParseErrorStack(c, errgo.ErrorStack(test.err))
+		checkParseDetails(c, errgo.Details(test.err))
+	}
+}
+
+func (*parseSuite) TestParseErrorStackInvalid(c *gc.C) {
+	entries, err := errgo.ParseErrorStack("")
+	c.Assert(err, gc.IsNil)
+	c.Assert(entries, gc.IsNil)
+	_, err = errgo.ParseErrorStack("\tfoo.go:1 foo")
+	c.Assert(err, gc.ErrorMatches, `cannot parse error stack: unexpected indented first line "\\tfoo.go:1 foo"`)
+}
+
+func (*parseSuite) TestParseDetails(c *gc.C) {
+	err := errgo.New("first {a}")                                          //err parseDetailsFirst
+	err = errgo.AnnotateWith(err, "loading} {user}", errgo.F("id", "a b")) //err parseDetailsAnnotate
+	err = errgo.Trace(err)                                                 //err parseDetailsTrace
+	err = errgo.Annotate(err, "{x} {y}: z")                                //err parseDetailsLast
+	text := errgo.Details(err)
+	entries, parseErr := errgo.ParseDetails(text)
+	c.Assert(parseErr, gc.IsNil)
+	function := "github.com/hifx/errgo_test.(*parseSuite).TestParseDetails"
+	c.Assert(entries, jc.DeepEquals, []errgo.StackEntry{
+		entryAt("parseDetailsLast", function, "{x} {y}: z", "first {a}"),
+		entryAt("parseDetailsTrace", function, "", "first {a}"),
+		entryAt("parseDetailsAnnotate", function, `loading} {user} id="a b"`, "first {a}"),
+		entryAt("parseDetailsFirst", function, "first {a}", "first {a}"),
+	})
+	c.Assert(detailsText(entries), gc.Equals, text)
+}
+
+func (*parseSuite) TestParseDetailsWithoutLocation(c *gc.C) {
+	err := errgo.Annotate(io.EOF, "reading: {header}") //err parseDetailsForeign
+	text := errgo.Details(err)
+	entries, parseErr := errgo.ParseDetails(text)
+	c.Assert(parseErr, gc.IsNil)
+	c.Assert(entries, jc.DeepEquals, []errgo.StackEntry{
+		entryAt("parseDetailsForeign", "github.com/hifx/errgo_test.(*parseSuite).TestParseDetailsWithoutLocation", "reading: {header}", "EOF"),
+		{Message: "EOF", Cause: "EOF"},
+	})
+	c.Assert(detailsText(entries), gc.Equals, text)
+
+	entries, parseErr = errgo.ParseDetails(errgo.Details(io.EOF))
+	c.Assert(parseErr, gc.IsNil)
+	c.Assert(entries, jc.DeepEquals, []errgo.StackEntry{{Message: "EOF", Cause: "EOF"}})
+}
+
+func (*parseSuite) TestParseDetailsInvalid(c *gc.C) {
+	entries, err := errgo.ParseDetails(errgo.Details(nil))
+	c.Assert(err, gc.IsNil)
+	c.Assert(entries, gc.IsNil)
+	_, err = errgo.ParseDetails("{foo}")
+	c.Assert(err, gc.ErrorMatches, `cannot parse details "{foo}": not enclosed in \[{ }\]`)
+}
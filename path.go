@@ -5,39 +5,90 @@ package errgo
 
 import (
 	"os"
-	"runtime"
+	"path"
+	"path/filepath"
+	"runtime/debug"
 	"strings"
 )
 
-// prefixSize is used internally to trim the user specific path from the
-// front of the returned filenames from the runtime call stack.
-var prefixSize int
+// modulePaths holds the module paths known to the running binary, the main
+// module first. They are used to trim the locations of functions in package
+// main, whose import path says nothing about where their source lives.
+var modulePaths []string
 
-// goPath is the deduced path based on the location of this file as compiled.
-var goPath string
+// mainPackagePath holds the import path of package main of the running
+// binary, if known. It is used to trim the locations of functions in
+// package main whose source is outside any known module path.
+var mainPackagePath string
 
 func init() {
-	_, file, _, ok := runtime.Caller(0)
-	if file == "?" {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
 		return
 	}
-	if ok {
-		// We know that the end of the file should be:
-		// github.com/hifx/errgo/path.go
-		size := len(file)
-		suffix := len("github.com/hifx/errgo/path.go")
-		goPath = file[:size-suffix]
-		prefixSize = len(goPath)
+	mainPackagePath = info.Path
+	if info.Main.Path != "" {
+		modulePaths = append(modulePaths, info.Main.Path)
+	}
+	for _, dep := range info.Deps {
+		modulePaths = append(modulePaths, dep.Path)
 	}
 }
 
-func trimGoPath(filename string) string {
-	if strings.HasPrefix(filename, goPath) {
-		return filename[prefixSize:]
+// trimSourcePath returns a stable name for the source file filename that
+// holds the given fully qualified function, of the form
+// "import/path/of/pkg/file.go". The name is derived from the import path
+// of the function's package, so it does not depend on GOPATH, the module
+// cache, vendoring or -trimpath. Functions in package main are located
+// using the module paths from the build information instead, or failing
+// that the import path of package main. If no better name can be found,
+// filename is returned unchanged.
+func trimSourcePath(filename, function string) string {
+	if filename == "" || filename == "?" {
+		return filename
+	}
+	filename = filepath.ToSlash(filename)
+	pkg := packagePath(function)
+	if pkg != "" && pkg != "main" {
+		return pkg + "/" + path.Base(filename)
+	}
+	for _, mod := range modulePaths {
+		if strings.HasPrefix(filename, mod+"/") {
+			return filename
+		}
+		if i := strings.LastIndex(filename, "/"+mod+"/"); i >= 0 {
+			return filename[i+1:]
+		}
+	}
+	if pkg == "main" && mainPackagePath != "" {
+		return mainPackagePath + "/" + path.Base(filename)
 	}
 	return filename
 }
 
+// packagePath returns the import path of the package holding the fully
+// qualified function, as returned by runtime.FuncForPC, or the empty
+// string if it cannot be determined. The "_test" suffix of external test
+// packages and any vendor directory prefix are removed.
+func packagePath(function string) string {
+	if i := strings.Index(function, "["); i >= 0 {
+		// Drop type parameters, which may contain further paths.
+		function = function[:i]
+	}
+	slashIndex := strings.LastIndex(function, "/")
+	dotIndex := strings.Index(function[slashIndex+1:], ".")
+	if dotIndex < 0 {
+		return ""
+	}
+	pkg := function[:slashIndex+1+dotIndex]
+	// The linker escapes dots in the last element of the import path.
+	pkg = strings.Replace(pkg, "%2e", ".", -1)
+	if i := strings.LastIndex(pkg, "/vendor/"); i >= 0 {
+		pkg = pkg[i+len("/vendor/"):]
+	}
+	return strings.TrimSuffix(pkg, "_test")
+}
+
 func trimPackage(function string) string {
 	slashIndex := strings.LastIndex(function, string(os.PathSeparator))
 	if slashIndex < 0 {
@@ -4,7 +4,7 @@
 package errgo_test
 
 import (
-	"path"
+	"runtime"
 
 	gc "gopkg.in/check.v1"
 
@@ -15,15 +15,89 @@ type pathSuite struct{}
 
 var _ = gc.Suite(&pathSuite{})
 
-func (*pathSuite) TestGoPathSet(c *gc.C) {
-	c.Assert(errgo.GoPath(), gc.Not(gc.Equals), "")
+func (*pathSuite) TestPackagePath(c *gc.C) {
+	for i, test := range []struct {
+		function string
+		expected string
+	}{
+		{"github.com/foo/bar.Baz", "github.com/foo/bar"},
+		{"github.com/foo/bar.(*Baz).Method", "github.com/foo/bar"},
+		{"github.com/foo/bar.Baz.func1.2", "github.com/foo/bar"},
+		{"github.com/foo/bar_test.TestBaz", "github.com/foo/bar"},
+		{"github.com/foo/bar.Map[go.shape.string,example.com/x.T]", "github.com/foo/bar"},
+		{"gopkg.in/check%2ev1.(*C).Fail", "gopkg.in/check.v1"},
+		{"github.com/me/app/vendor/github.com/foo/bar.Baz", "github.com/foo/bar"},
+		{"main.main", "main"},
+		{"", ""},
+		{"unknown", ""},
+	} {
+		c.Logf("%v: %s", i, test.function)
+		c.Check(errgo.PackagePath(test.function), gc.Equals, test.expected)
+	}
 }
 
-func (*pathSuite) TestTrimGoPath(c *gc.C) {
-	relativeImport := "github.com/foo/bar/baz.go"
-	filename := path.Join(errgo.GoPath(), relativeImport)
-	c.Assert(errgo.TrimGoPath(filename), gc.Equals, relativeImport)
+func (*pathSuite) TestTrimSourcePath(c *gc.C) {
+	defer errgo.SetModulePaths([]string{"github.com/me/app", "github.com/foo/bar"})()
+	defer errgo.SetMainPackagePath("github.com/me/app/cmd/app")()
+	for i, test := range []struct {
+		filename string
+		function string
+		expected string
+	}{{
+		// GOPATH build.
+		"/home/me/go/src/github.com/foo/bar/baz.go", "github.com/foo/bar.Baz",
+		"github.com/foo/bar/baz.go",
+	}, {
+		// Module cache.
+		"/home/me/go/pkg/mod/github.com/foo/bar@v1.2.3/baz.go", "github.com/foo/bar.Baz",
+		"github.com/foo/bar/baz.go",
+	}, {
+		// Module checked out anywhere.
+		"/work/checkout/baz.go", "github.com/foo/bar.Baz",
+		"github.com/foo/bar/baz.go",
+	}, {
+		// Vendored package.
+		"/work/app/vendor/github.com/foo/bar/baz.go", "github.com/foo/bar.Baz",
+		"github.com/foo/bar/baz.go",
+	}, {
+		// Built with -trimpath.
+		"github.com/foo/bar@v1.2.3/baz.go", "github.com/foo/bar.Baz",
+		"github.com/foo/bar/baz.go",
+	}, {
+		// External test package.
+		"/work/checkout/baz_test.go", "github.com/foo/bar_test.TestBaz",
+		"github.com/foo/bar/baz_test.go",
+	}, {
+		"/work/checkout/github.com/me/app/cmd/app/main.go", "main.main",
+		"github.com/me/app/cmd/app/main.go",
+	}, {
+		"github.com/me/app/cmd/app/main.go", "main.main",
+		"github.com/me/app/cmd/app/main.go",
+	}, {
+		// Package main outside any known module path.
+		"/work/checkout/cmd/app/main.go", "main.main",
+		"github.com/me/app/cmd/app/main.go",
+	}, {
+		"/usr/share/foo/bar/baz.go", "",
+		"/usr/share/foo/bar/baz.go",
+	}, {
+		"?", "github.com/foo/bar.Baz",
+		"?",
+	}} {
+		c.Logf("%v: %s", i, test.filename)
+		c.Check(errgo.TrimSourcePath(test.filename, test.function), gc.Equals, test.expected)
+	}
+}
+
+func (*pathSuite) TestTrimSourcePathUnknownMain(c *gc.C) {
+	defer errgo.SetModulePaths(nil)()
+	defer errgo.SetMainPackagePath("")()
+	c.Assert(errgo.TrimSourcePath("/work/checkout/cmd/app/main.go", "main.main"), gc.Equals, "/work/checkout/cmd/app/main.go")
+}
 
-	absoluteImport := "/usr/share/foo/bar/baz.go"
-	c.Assert(errgo.TrimGoPath(absoluteImport), gc.Equals, absoluteImport)
+func (*pathSuite) TestTrimSourcePathOfCaller(c *gc.C) {
+	pc, file, _, ok := runtime.Caller(0)
+	c.Assert(ok, gc.Equals, true)
+	function := runtime.FuncForPC(pc).Name()
+	c.Assert(errgo.TrimSourcePath(file, function), gc.Equals, "github.com/hifx/errgo/path_test.go")
 }
@@ -0,0 +1,158 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ProblemContentType is the HTTP content type of an RFC 7807 problem
+// details response.
+const ProblemContentType = "application/problem+json"
+
+// Problem holds the problem details of an HTTP API error, as described by
+// RFC 7807.
+type Problem struct {
+	// Type is a URI reference identifying the problem type. It defaults
+	// to "about:blank", meaning the problem is described by Status alone.
+	Type string
+
+	// Title is a short summary of the problem type.
+	Title string
+
+	// Status is the HTTP status code of the response.
+	Status int
+
+	// Detail explains this occurrence of the problem.
+	Detail string
+
+	// Instance is a URI reference identifying this occurrence of the
+	// problem.
+	Instance string
+
+	// Extensions holds any additional members of the problem details
+	// object. Members with the names of the standard members are ignored.
+	Extensions map[string]interface{}
+}
+
+type problemExtender interface {
+	// ProblemExtensions returns extension members to be added to the
+	// problem details of an error.
+	ProblemExtensions() map[string]interface{}
+}
+
+// NewProblem returns the problem details for err. The status is the HTTP
+// code of err as returned by Code, or 500 for errors that carry no HTTP
+// code at all. The detail is the public message of err, as returned by
+// PublicMessage, or otherwise, as for the body written by WriteError, the
+// message of the error carrying the HTTP code with sensitive data
+// redacted, or the status text for errors without a code. Extension
+// members are gathered from every error in the stack that has a
+// ProblemExtensions method, with the most recent annotation winning, and
+// from the context values recorded in the stack, such as the request ID,
+// as returned by ContextValues.
+func NewProblem(err error) *Problem {
+	status := Code(err)
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	p := &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+	}
+	if p.Detail = PublicMessage(err); p.Detail == "" && err != nil {
+		p.Detail = http.StatusText(status)
+		if e := coderOf(err); e != nil {
+			p.Detail = codedMessage(e)
+		}
+	}
+	values := ContextValues(err)
+	for ; err != nil; err = underlying(err) {
+		err, ok := err.(problemExtender)
+		if !ok {
+			continue
+		}
+		for name, value := range err.ProblemExtensions() {
+			if p.Extensions == nil {
+				p.Extensions = make(map[string]interface{})
+			}
+			if _, ok := p.Extensions[name]; !ok {
+				p.Extensions[name] = value
+			}
+		}
+	}
+	for name, value := range values {
+		if p.Extensions == nil {
+			p.Extensions = make(map[string]interface{})
+		}
+		if _, ok := p.Extensions[name]; !ok {
+			p.Extensions[name] = value
+		}
+	}
+	return p
+}
+
+// underlying returns the previous error in the stack of err, or nil.
+func underlying(err error) error {
+	if err, ok := err.(wrapper); ok {
+		return err.Underlying()
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, writing the extension members
+// alongside the standard ones.
+func (p Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]interface{}, len(p.Extensions)+5)
+	for name, value := range p.Extensions {
+		members[name] = value
+	}
+	members["type"] = p.Type
+	members["title"] = p.Title
+	members["status"] = p.Status
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	} else {
+		delete(members, "detail")
+	}
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	} else {
+		delete(members, "instance")
+	}
+	return json.Marshal(members)
+}
+
+// Write writes the problem details to w as an application/problem+json
+// response with the problem's status code.
+func (p *Problem) Write(w http.ResponseWriter) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return Trace(err)
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_, err = w.Write(data)
+	return Trace(err)
+}
+
+// WriteProblem writes err to w as an RFC 7807 problem details response, as
+// built by NewProblem. If r is not nil, the request URI is used as the
+// problem instance, and the detail is translated into a language accepted
+// by r if a translation has been registered with RegisterTranslation.
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) error {
+	p := NewProblem(err)
+	if r != nil && r.URL != nil {
+		p.Instance = r.URL.RequestURI()
+	}
+	if r != nil {
+		if detail, ok := localize(err, ParseAcceptLanguage(r.Header.Get("Accept-Language"))); ok {
+			p.Detail = detail
+		}
+	}
+	return p.Write(w)
+}
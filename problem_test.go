@@ -0,0 +1,191 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/hifx/errgo"
+)
+
+type problemSuite struct{}
+
+var _ = gc.Suite(&problemSuite{})
+
+type extendedError struct {
+	errgo.Err
+	extensions map[string]interface{}
+}
+
+func (e *extendedError) ProblemExtensions() map[string]interface{} {
+	return e.extensions
+}
+
+func newExtendedError(extensions map[string]interface{}) error {
+	err := &extendedError{errgo.NewErr(http.StatusConflict, "conflict"), extensions}
+	err.SetLocation(1)
+	return err
+}
+
+func (*problemSuite) TestNewProblem(c *gc.C) {
+	for i, test := range []struct {
+		message  string
+		err      error
+		expected *errgo.Problem
+	}{{
+		message: "plain error",
+		err:     fmt.Errorf("raw"),
+		expected: &errgo.Problem{
+			Type:   "about:blank",
+			Title:  "Internal Server Error",
+			Status: 500,
+			Detail: "Internal Server Error",
+		},
+	}, {
+		message: "error with sensitive argument",
+		err:     errgo.Annotatef(errgo.New("first"), "loading %s", errgo.Sensitive("jo@example.com")),
+		expected: &errgo.Problem{
+			Type:   "about:blank",
+			Title:  "Internal Server Error",
+			Status: 500,
+			Detail: "Internal Server Error",
+		},
+	}, {
+		message: "error with public message",
+		err: errgo.WithPublicMessage(
+			errgo.NotFoundf("row 42 missing in table users"),
+			"Resource not found",
+		),
+		expected: &errgo.Problem{
+			Type:   "about:blank",
+			Title:  "Not Found",
+			Status: 404,
+			Detail: "Resource not found",
+		},
+	}, {
+		message: "errgo error without a code",
+		err:     errgo.Annotate(errgo.New("first"), "annotation"),
+		expected: &errgo.Problem{
+			Type:   "about:blank",
+			Title:  "Internal Server Error",
+			Status: 500,
+			Detail: "Internal Server Error",
+		},
+	}, {
+		message: "annotated http error",
+		err:     errgo.Annotate(errgo.NotFoundf("user %d", 42), "loading"),
+		expected: &errgo.Problem{
+			Type:   "about:blank",
+			Title:  "Not Found",
+			Status: 404,
+			Detail: "user 42",
+		},
+	}, {
+		message: "http error with sensitive argument",
+		err:     errgo.Annotate(errgo.NotFoundf("user %s", errgo.Sensitive("jo@example.com")), "loading"),
+		expected: &errgo.Problem{
+			Type:   "about:blank",
+			Title:  "Not Found",
+			Status: 404,
+			Detail: "user [REDACTED]",
+		},
+	}, {
+		message: "http error wrapping another",
+		err:     errgo.NewBadRequest(fmt.Errorf("missing field"), "invalid body"),
+		expected: &errgo.Problem{
+			Type:   "about:blank",
+			Title:  "Bad Request",
+			Status: 400,
+			Detail: "invalid body",
+		},
+	}, {
+		message: "wrapped into an http error",
+		err:     errgo.Wrap(fmt.Errorf("no rows"), errgo.MethodNotAllowedf("read only")),
+		expected: &errgo.Problem{
+			Type:   "about:blank",
+			Title:  "Method Not Allowed",
+			Status: 405,
+			Detail: "read only",
+		},
+	}, {
+		message: "masked http error",
+		err:     errgo.Mask(errgo.Unauthorizedf("bad token")),
+		expected: &errgo.Problem{
+			Type:   "about:blank",
+			Title:  "Unauthorized",
+			Status: 401,
+			Detail: "bad token",
+		},
+	}, {
+		message: "extensions",
+		err: errgo.Trace(newExtendedError(map[string]interface{}{
+			"balance": 30,
+			"status":  200,
+		})),
+		expected: &errgo.Problem{
+			Type:   "about:blank",
+			Title:  "Conflict",
+			Status: 409,
+			Detail: "conflict",
+			Extensions: map[string]interface{}{
+				"balance": 30,
+				"status":  200,
+			},
+		},
+	}} {
+		c.Logf("%v: %s", i, test.message)
+		c.Check(errgo.NewProblem(test.err), jc.DeepEquals, test.expected)
+	}
+}
+
+func (*problemSuite) TestWriteProblem(c *gc.C) {
+	err := errgo.Annotate(newExtendedError(map[string]interface{}{
+		"balance": 30,
+		"title":   "ignored",
+	}), "buying")
+	req := httptest.NewRequest("POST", "/account/12345/msgs?x=1", nil)
+	rec := httptest.NewRecorder()
+	c.Assert(errgo.WriteProblem(rec, req, err), gc.IsNil)
+
+	c.Assert(rec.Code, gc.Equals, http.StatusConflict)
+	c.Assert(rec.Header().Get("Content-Type"), gc.Equals, "application/problem+json")
+	var body map[string]interface{}
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &body), gc.IsNil)
+	c.Assert(body, jc.DeepEquals, map[string]interface{}{
+		"type":     "about:blank",
+		"title":    "Conflict",
+		"status":   float64(409),
+		"detail":   "conflict",
+		"instance": "/account/12345/msgs?x=1",
+		"balance":  float64(30),
+	})
+}
+
+func (*problemSuite) TestMarshalProblemValue(c *gc.C) {
+	p := errgo.NewProblem(newExtendedError(map[string]interface{}{"balance": 30}))
+	expected := `{"balance":30,"detail":"conflict","status":409,"title":"Conflict","type":"about:blank"}`
+	data, err := json.Marshal(*p)
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(data), gc.Equals, expected)
+
+	data, err = json.Marshal(struct {
+		Problem errgo.Problem `json:"problem"`
+	}{*p})
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(data), gc.Equals, `{"problem":`+expected+`}`)
+}
+
+func (*problemSuite) TestWriteProblemWithoutRequest(c *gc.C) {
+	rec := httptest.NewRecorder()
+	c.Assert(errgo.WriteProblem(rec, nil, fmt.Errorf("raw")), gc.IsNil)
+	c.Assert(rec.Code, gc.Equals, http.StatusInternalServerError)
+	c.Assert(rec.Body.String(), gc.Equals,
+		`{"detail":"Internal Server Error","status":500,"title":"Internal Server Error","type":"about:blank"}`)
+}
@@ -0,0 +1,79 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo
+
+import (
+	"fmt"
+)
+
+// WithPublicMessage returns a new error in the stack of other, keeping its
+// cause, which carries a message to be shown to clients in place of the
+// internal messages, which are kept for diagnostics. The location of the
+// call is recorded. WithPublicMessage returns nil if other is nil.
+//
+// For example:
+//     if err := loadRow(42); err != nil {
+//         return errgo.WithPublicMessage(err, "Resource not found")
+//     }
+func WithPublicMessage(other error, message string) error {
+	if other == nil {
+		return nil
+	}
+	err := &Err{
+		previous:      other,
+		cause:         Cause(other),
+		publicMessage: message,
+	}
+	err.SetLocation(1)
+	return err
+}
+
+// NewPublic returns a new error with the given HTTP response code, public
+// message and internal message, which is formatted from format and args
+// like fmt.Sprintf.
+//
+// For example:
+//     return errgo.NewPublic(http.StatusNotFound, "Resource not found", "row %d missing in table %s", id, table)
+func NewPublic(code int, public string, format string, args ...interface{}) error {
+	err := &Err{
+		message:       fmt.Sprintf(format, args...),
+		safeMessage:   redactf(format, args),
+		format:        format,
+		code:          code,
+		publicMessage: public,
+	}
+	err.SetLocation(1)
+	err.captureStack(1)
+	return err
+}
+
+// SetPublicMessage sets the message to be shown to clients for this error.
+func (e *Err) SetPublicMessage(message string) {
+	e.publicMessage = message
+}
+
+// PublicMessage returns the message to be shown to clients set for this
+// entry in the error stack, if any.
+func (e *Err) PublicMessage() string {
+	return e.publicMessage
+}
+
+type publicMessager interface {
+	PublicMessage() string
+}
+
+var _ publicMessager = (*Err)(nil)
+
+// PublicMessage returns the most recent public message in the stack of
+// err, searched as for Code, or the empty string if there is none.
+func PublicMessage(err error) string {
+	found := findError(err, func(e error) bool {
+		e1, ok := e.(publicMessager)
+		return ok && e1.PublicMessage() != ""
+	})
+	if found == nil {
+		return ""
+	}
+	return found.(publicMessager).PublicMessage()
+}
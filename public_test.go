@@ -0,0 +1,73 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/hifx/errgo"
+)
+
+type publicSuite struct{}
+
+var _ = gc.Suite(&publicSuite{})
+
+func (*publicSuite) TestWithPublicMessage(c *gc.C) {
+	first := errgo.New("row 42 missing in table users")
+	err := errgo.WithPublicMessage(first, "Resource not found")
+	c.Assert(err.Error(), gc.Equals, "row 42 missing in table users")
+	c.Assert(errgo.Cause(err), gc.Equals, first)
+	c.Assert(err.(*errgo.Err).PublicMessage(), gc.Equals, "Resource not found")
+	c.Assert(errgo.PublicMessage(err), gc.Equals, "Resource not found")
+
+	c.Assert(errgo.WithPublicMessage(nil, "Resource not found"), gc.IsNil)
+}
+
+func (*publicSuite) TestNewPublic(c *gc.C) {
+	err := errgo.NewPublic(http.StatusNotFound, "Resource not found", "row %d missing in table %s", 42, "users")
+	c.Assert(err.Error(), gc.Equals, "row 42 missing in table users")
+	c.Assert(errgo.Code(err), gc.Equals, http.StatusNotFound)
+	c.Assert(errgo.IsNotFound(err), jc.IsTrue)
+	c.Assert(errgo.PublicMessage(err), gc.Equals, "Resource not found")
+}
+
+func (*publicSuite) TestPublicMessageSearchesStack(c *gc.C) {
+	c.Assert(errgo.PublicMessage(nil), gc.Equals, "")
+	c.Assert(errgo.PublicMessage(fmt.Errorf("raw")), gc.Equals, "")
+	c.Assert(errgo.PublicMessage(errgo.New("first")), gc.Equals, "")
+
+	err := errgo.WithPublicMessage(errgo.New("first"), "Something went wrong")
+	err = errgo.Annotate(err, "annotation")
+	c.Assert(errgo.PublicMessage(err), gc.Equals, "Something went wrong")
+
+	// The most recent public message wins.
+	err = errgo.WithPublicMessage(err, "Try again later")
+	c.Assert(errgo.PublicMessage(err), gc.Equals, "Try again later")
+
+	// A public message on the error passed to Wrap is found first.
+	newErr := errgo.NewErr(http.StatusConflict, "conflict")
+	newErr.SetPublicMessage("Already exists")
+	err = errgo.Wrap(err, &newErr)
+	c.Assert(errgo.PublicMessage(err), gc.Equals, "Already exists")
+
+	// Standard wrapping is seen through.
+	err = fmt.Errorf("wrapped: %w", err)
+	c.Assert(errgo.PublicMessage(err), gc.Equals, "Already exists")
+}
+
+func (*publicSuite) TestJSON(c *gc.C) {
+	err := errgo.WithPublicMessage(errgo.New("first"), "Resource not found")
+	data, jsonErr := json.Marshal(err)
+	c.Assert(jsonErr, gc.IsNil)
+	c.Assert(string(data), jc.Contains, `"publicMessage":"Resource not found"`)
+
+	var obtained errgo.Err
+	c.Assert(json.Unmarshal(data, &obtained), gc.IsNil)
+	c.Assert(errgo.PublicMessage(&obtained), gc.Equals, "Resource not found")
+}
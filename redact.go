@@ -0,0 +1,274 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// RedactedText replaces sensitive data in the safe rendering of errors.
+const RedactedText = "[REDACTED]"
+
+// SensitiveValue holds a value marked as sensitive by Sensitive.
+type SensitiveValue struct {
+	Value interface{}
+}
+
+// Sensitive marks value as sensitive, such as an email address, a token or
+// the parameters of a query. When passed as an argument to Errorf,
+// Annotatef and the other formatting constructors, or as the value of a
+// Field, the value is shown in full by Error, ErrorStack and Details, but
+// is replaced by RedactedText by SafeError and SafeFields.
+//
+// For example:
+//     return errgo.Annotatef(err, "cannot send mail to %s", errgo.Sensitive(email))
+func Sensitive(value interface{}) SensitiveValue {
+	return SensitiveValue{value}
+}
+
+// Format implements fmt.Formatter, formatting the value itself.
+func (v SensitiveValue) Format(s fmt.State, verb rune) {
+	fmt.Fprintf(s, fmt.FormatString(s, verb), v.Value)
+}
+
+// MarshalJSON implements json.Marshaler, encoding the value itself, so that
+// the JSON encoding of an error keeps its full detail.
+func (v SensitiveValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.Value)
+}
+
+// redactedValue formats as RedactedText whatever the verb.
+type redactedValue struct{}
+
+func (redactedValue) Format(s fmt.State, verb rune) {
+	fmt.Fprint(s, RedactedText)
+}
+
+var rules = struct {
+	mu       sync.RWMutex
+	keys     map[string]bool
+	patterns []*regexp.Regexp
+}{
+	keys: make(map[string]bool),
+}
+
+// RedactKeys registers field keys, such as "password" or "token", whose
+// values are always redacted by SafeFields. Keys are matched without
+// regard to case.
+func RedactKeys(keys ...string) {
+	rules.mu.Lock()
+	defer rules.mu.Unlock()
+	for _, key := range keys {
+		rules.keys[strings.ToLower(key)] = true
+	}
+}
+
+// RedactPattern registers a regular expression whose matches, such as
+// email addresses, are replaced by RedactedText in the messages returned
+// by SafeError.
+func RedactPattern(re *regexp.Regexp) {
+	rules.mu.Lock()
+	defer rules.mu.Unlock()
+	rules.patterns = append(rules.patterns, re)
+}
+
+// redactf returns the message formatted from format and args with any
+// sensitive arguments redacted, or the empty string if there are none.
+func redactf(format string, args []interface{}) string {
+	var redacted []interface{}
+	for i, arg := range args {
+		if _, ok := arg.(SensitiveValue); !ok {
+			continue
+		}
+		if redacted == nil {
+			redacted = append([]interface{}(nil), args...)
+		}
+		redacted[i] = redactedValue{}
+	}
+	if redacted == nil {
+		return ""
+	}
+	return fmt.Sprintf(format, redacted...)
+}
+
+// redactText returns s with the matches of the registered patterns
+// replaced by RedactedText.
+func redactText(s string) string {
+	rules.mu.RLock()
+	defer rules.mu.RUnlock()
+	for _, re := range rules.patterns {
+		s = re.ReplaceAllString(s, RedactedText)
+	}
+	return s
+}
+
+// redactField returns field with its value replaced by RedactedText if it
+// is sensitive or its key is registered with RedactKeys.
+func redactField(field Field) Field {
+	if _, ok := field.Value.(SensitiveValue); ok {
+		return Field{field.Key, RedactedText}
+	}
+	rules.mu.RLock()
+	defer rules.mu.RUnlock()
+	if rules.keys[strings.ToLower(field.Key)] {
+		return Field{field.Key, RedactedText}
+	}
+	return field
+}
+
+// SafeError returns the message of err, as returned by its Error method,
+// with sensitive arguments and the matches of the patterns registered with
+// RedactPattern replaced by RedactedText. It is used for anything shown
+// outside the process, such as HTTP responses, whereas ErrorStack and
+// Details keep the full detail. Errors from outside this package are
+// rendered by their own SafeError method, if any, or by Error, with the
+// messages of any errors they wrap through Unwrap redacted in turn.
+func SafeError(err error) string {
+	if err == nil {
+		return ""
+	}
+	return redactText(safeError(err))
+}
+
+// SafeError returns the message of the error with sensitive data
+// redacted, as for the SafeError function.
+func (e *Err) SafeError() string {
+	return SafeError(e)
+}
+
+// SafeError returns the messages of the errors held by m with sensitive
+// data redacted, as for the SafeError function.
+func (m *MultiError) SafeError() string {
+	return SafeError(m)
+}
+
+// SafeMessage returns the message stored with the most recent location,
+// as returned by Message, with sensitive data redacted.
+func (e *Err) SafeMessage() string {
+	if e.safeMessage != "" {
+		return redactText(e.safeMessage)
+	}
+	return redactText(e.message)
+}
+
+// SafeMessage returns the same as SafeError, as Message returns the same
+// as Error.
+func (m *MultiError) SafeMessage() string {
+	return m.SafeError()
+}
+
+type safeErrorer interface {
+	SafeError() string
+}
+
+type safeMessager interface {
+	SafeMessage() string
+}
+
+var (
+	_ safeErrorer  = (*Err)(nil)
+	_ safeMessager = (*Err)(nil)
+	_ safeErrorer  = (*MultiError)(nil)
+	_ safeMessager = (*MultiError)(nil)
+)
+
+// safeError returns the message of err with sensitive arguments redacted,
+// leaving the patterns to the caller.
+func safeError(err error) string {
+	switch err := err.(type) {
+	case *MultiError:
+		messages := make([]string, len(err.errs))
+		for i, err := range err.errs {
+			messages[i] = safeError(err)
+		}
+		return strings.Join(messages, "; ")
+	case *Err:
+		// Walk the stack as Error does.
+		message := err.message
+		if err.safeMessage != "" {
+			message = err.safeMessage
+		}
+		next := err.previous
+		if !sameError(Cause(next), err.cause) && err.cause != nil {
+			next = err.cause
+		}
+		switch {
+		case next == nil:
+			return message
+		case message == "":
+			return safeError(next)
+		}
+		return message + ": " + safeError(next)
+	case safeErrorer:
+		return err.SafeError()
+	case interface {
+		error
+		Unwrap() error
+	}:
+		return safeWrapped(err.Error(), err.Unwrap())
+	case interface {
+		error
+		Unwrap() []error
+	}:
+		return safeWrapped(err.Error(), err.Unwrap()...)
+	}
+	return err.Error()
+}
+
+// safeWrapped returns text, the message of an error from outside this
+// package, with the messages of the errors it wraps replaced by their
+// redacted messages. If such a message needs redacting but is not part of
+// text, RedactedText is returned in place of text.
+func safeWrapped(text string, errs ...error) string {
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		full, safe := err.Error(), safeError(err)
+		if full == safe {
+			continue
+		}
+		if !strings.Contains(text, full) {
+			return RedactedText
+		}
+		text = strings.Replace(text, full, safe, -1)
+	}
+	return text
+}
+
+// safeMessage returns the message of err, as used for the body of an HTTP
+// response, with sensitive data redacted, or the empty string if err has
+// no message of its own.
+func safeMessage(err error) string {
+	switch err := err.(type) {
+	case safeMessager:
+		return err.SafeMessage()
+	case wrapper:
+		return redactText(err.Message())
+	}
+	return ""
+}
+
+// SafeErrorStack returns the error stack of err, as returned by
+// ErrorStack, with sensitive data redacted from its messages and fields,
+// as for SafeError and SafeFields. It is used where the stack leaves the
+// process, such as in logs and error reports.
+func SafeErrorStack(err error) string {
+	return strings.Join(stackLines(err, trimPackage, true), "\n")
+}
+
+// SafeFields returns the fields of the error stack of err, as returned by
+// Fields, with sensitive values and the values of the keys registered with
+// RedactKeys replaced by RedactedText.
+func SafeFields(err error) []Field {
+	fields := Fields(err)
+	for i, field := range fields {
+		fields[i] = redactField(field)
+	}
+	return fields
+}
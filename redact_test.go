@@ -0,0 +1,200 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo_test
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/hifx/errgo"
+)
+
+type redactSuite struct {
+	restore func()
+}
+
+var _ = gc.Suite(&redactSuite{})
+
+func (s *redactSuite) SetUpTest(c *gc.C) {
+	s.restore = errgo.ResetRedactionRules()
+}
+
+func (s *redactSuite) TearDownTest(c *gc.C) {
+	s.restore()
+}
+
+func (*redactSuite) TestSensitiveArguments(c *gc.C) {
+	email := errgo.Sensitive("jo@example.com")
+	for i, test := range []struct {
+		message string
+		err     error
+		full    string
+		safe    string
+	}{{
+		message: "Errorf",
+		err:     errgo.Errorf("no user %s", email),
+		full:    "no user jo@example.com",
+		safe:    "no user [REDACTED]",
+	}, {
+		message: "Annotatef",
+		err:     errgo.Annotatef(errgo.New("first"), "mailing %q", email),
+		full:    `mailing "jo@example.com": first`,
+		safe:    "mailing [REDACTED]: first",
+	}, {
+		message: "Wrapf",
+		err:     errgo.Wrapf(errgo.New("first"), errgo.Errorf("user %v", email), "mailing %s", email),
+		full:    "mailing jo@example.com: user jo@example.com",
+		safe:    "mailing [REDACTED]: user [REDACTED]",
+	}, {
+		message: "Maskf",
+		err:     errgo.Maskf(errgo.New("first"), "mailing %s", email),
+		full:    "mailing jo@example.com: first",
+		safe:    "mailing [REDACTED]: first",
+	}, {
+		message: "NotFoundf",
+		err:     errgo.NotFoundf("user %s", email),
+		full:    "user jo@example.com",
+		safe:    "user [REDACTED]",
+	}, {
+		message: "other verbs",
+		err:     errgo.Errorf("id %d of %s", errgo.Sensitive(42), "users"),
+		full:    "id 42 of users",
+		safe:    "id [REDACTED] of users",
+	}, {
+		message: "MultiError",
+		err:     errgo.Join(errgo.Errorf("user %s", email), fmt.Errorf("raw")),
+		full:    "user jo@example.com; raw",
+		safe:    "user [REDACTED]; raw",
+	}, {
+		message: "foreign error",
+		err:     errgo.Annotate(fmt.Errorf("raw"), "annotation"),
+		full:    "annotation: raw",
+		safe:    "annotation: raw",
+	}} {
+		c.Logf("%v: %s", i, test.message)
+		c.Check(test.err.Error(), gc.Equals, test.full)
+		c.Check(errgo.SafeError(test.err), gc.Equals, test.safe)
+		c.Check(test.err.(interface{ SafeError() string }).SafeError(), gc.Equals, test.safe)
+	}
+	c.Assert(errgo.SafeError(nil), gc.Equals, "")
+}
+
+// customWrapper wraps an error, writing its message with its spaces
+// replaced.
+type customWrapper struct {
+	err error
+}
+
+func (e customWrapper) Error() string { return strings.Replace(e.err.Error(), " ", "_", -1) }
+func (e customWrapper) Unwrap() error { return e.err }
+
+func (*redactSuite) TestForeignWrapper(c *gc.C) {
+	mail := errgo.Errorf("mail %s", errgo.Sensitive("bob@x.com"))
+	for i, test := range []struct {
+		message string
+		err     error
+		safe    string
+	}{{
+		message: "fmt.Errorf",
+		err:     fmt.Errorf("wrapped: %w", mail),
+		safe:    "wrapped: mail [REDACTED]",
+	}, {
+		message: "annotated fmt.Errorf",
+		err:     errgo.Annotate(fmt.Errorf("wrapped: %w", mail), "sending"),
+		safe:    "sending: wrapped: mail [REDACTED]",
+	}, {
+		message: "several wrapped errors",
+		err:     fmt.Errorf("%w, %w", io.EOF, fmt.Errorf("wrapped: %w", mail)),
+		safe:    "EOF, wrapped: mail [REDACTED]",
+	}, {
+		message: "errors.Join",
+		err:     stderrors.Join(io.EOF, mail),
+		safe:    "EOF\nmail [REDACTED]",
+	}, {
+		message: "message changed",
+		err:     customWrapper{mail},
+		safe:    "[REDACTED]",
+	}, {
+		message: "nested message changed",
+		err:     fmt.Errorf("wrapped: %w", customWrapper{mail}),
+		safe:    "wrapped: [REDACTED]",
+	}} {
+		c.Logf("%v: %s", i, test.message)
+		c.Check(test.err.Error(), jc.Contains, "bob@x.com")
+		c.Check(errgo.SafeError(test.err), gc.Equals, test.safe)
+		c.Check(errgo.SafeErrorStack(test.err), gc.Not(jc.Contains), "bob@x.com")
+	}
+}
+
+func (*redactSuite) TestErrorStackKeepsDetail(c *gc.C) {
+	err := errgo.Annotatef(errgo.New("first"), "mailing %s", errgo.Sensitive("jo@example.com"))
+	c.Assert(errgo.ErrorStack(err), jc.Contains, "mailing jo@example.com")
+	c.Assert(errgo.Details(err), jc.Contains, "mailing jo@example.com")
+}
+
+func (*redactSuite) TestSafeMessage(c *gc.C) {
+	err := errgo.Annotatef(errgo.New("first"), "mailing %s", errgo.Sensitive("jo@example.com"))
+	c.Assert(err.(*errgo.Err).Message(), gc.Equals, "mailing jo@example.com")
+	c.Assert(err.(*errgo.Err).SafeMessage(), gc.Equals, "mailing [REDACTED]")
+}
+
+func (*redactSuite) TestRedactPattern(c *gc.C) {
+	errgo.RedactPattern(regexp.MustCompile(`[\w.]+@[\w.]+`))
+	err := errgo.Annotate(fmt.Errorf("no mailbox jo@example.com"), "sending to bo@example.com")
+	c.Assert(err.Error(), gc.Equals, "sending to bo@example.com: no mailbox jo@example.com")
+	c.Assert(errgo.SafeError(err), gc.Equals, "sending to [REDACTED]: no mailbox [REDACTED]")
+	c.Assert(err.(*errgo.Err).SafeMessage(), gc.Equals, "sending to [REDACTED]")
+}
+
+func (*redactSuite) TestSafeFields(c *gc.C) {
+	errgo.RedactKeys("Password")
+	err := errgo.AnnotateWith(errgo.New("first"), "logging in",
+		errgo.F("user", 42),
+		errgo.F("email", errgo.Sensitive("jo@example.com")),
+		errgo.F("password", "hunter2"),
+	)
+	c.Assert(errgo.SafeFields(err), jc.DeepEquals, []errgo.Field{
+		{"user", 42},
+		{"email", "[REDACTED]"},
+		{"password", "[REDACTED]"},
+	})
+	c.Assert(errgo.Fields(err)[1].Value, gc.Equals, errgo.Sensitive("jo@example.com"))
+	c.Assert(errgo.ErrorStack(err), jc.Contains, "email=jo@example.com password=hunter2")
+}
+
+func (*redactSuite) TestSafeErrorStack(c *gc.C) {
+	errgo.RedactKeys("password")
+	errgo.RedactPattern(regexp.MustCompile(`[\w.]+@[\w.]+`))
+	err := errgo.Errorf("no user %s", errgo.Sensitive("jo"))                         //err redact-0 (*redactSuite).TestSafeErrorStack
+	err = errgo.AnnotateWith(err, "logging in", errgo.F("password", "hunter2"))      //err redact-1 (*redactSuite).TestSafeErrorStack
+	err = errgo.Wrap(err, fmt.Errorf("no mailbox jo@example.com"))                   //err redact-2 (*redactSuite).TestSafeErrorStack
+	err = errgo.Join(err, errgo.Annotatef(io.EOF, "token %s", errgo.Sensitive("t"))) //err redact-3 (*redactSuite).TestSafeErrorStack
+	c.Assert(errgo.SafeErrorStack(err), gc.Equals, replaceLocations(""+
+		"$redact-3$: no mailbox [REDACTED]; token [REDACTED]: EOF\n"+
+		"\t$redact-0$: no user [REDACTED]\n"+
+		"\t$redact-1$: logging in password=[REDACTED]\n"+
+		"\t$redact-2$: no mailbox [REDACTED]\n"+
+		"\tEOF\n"+
+		"\t$redact-3$: token [REDACTED]"))
+	c.Assert(errgo.ErrorStack(err), jc.Contains, "logging in password=hunter2")
+	c.Assert(errgo.SafeErrorStack(nil), gc.Equals, "")
+}
+
+func (*redactSuite) TestJSON(c *gc.C) {
+	err := errgo.Annotatef(errgo.New("first"), "mailing %s", errgo.Sensitive("jo@example.com"))
+	data, jsonErr := json.Marshal(err)
+	c.Assert(jsonErr, gc.IsNil)
+
+	var obtained errgo.Err
+	c.Assert(json.Unmarshal(data, &obtained), gc.IsNil)
+	c.Assert(obtained.Error(), gc.Equals, "mailing jo@example.com: first")
+	c.Assert(errgo.SafeError(&obtained), gc.Equals, "mailing [REDACTED]: first")
+}
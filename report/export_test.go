@@ -0,0 +1,18 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package report
+
+// Windows returns the number of rate limiting windows held by r.
+func Windows(r *Reporter) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.windows)
+}
+
+// Waiters returns the number of Flush calls waiting on r.
+func Waiters(r *Reporter) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.waiters)
+}
@@ -0,0 +1,393 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+// Package report delivers errgo errors to error reporting sinks, such as
+// logs, files, webhooks and Sentry, in the background.
+//
+// For example:
+//     report.Register(report.NewLogSink(slog.Default()))
+//     defer report.Flush(context.Background())
+//     ...
+//     if err := handle(ctx, req); err != nil {
+//         report.Report(ctx, err)
+//     }
+package report
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hifx/errgo"
+)
+
+// Event holds an error as delivered to sinks.
+type Event struct {
+	// Time holds the time the error was reported.
+	Time time.Time `json:"time"`
+
+	// Fingerprint groups events for the same error, as returned by
+	// Fingerprint.
+	Fingerprint string `json:"fingerprint"`
+
+	// Type holds the type of the Cause of the error.
+	Type string `json:"type"`
+
+	// Message holds the message of the error with sensitive data
+	// redacted, as returned by errgo.SafeError.
+	Message string `json:"message"`
+
+	// Code holds the HTTP response code of the error, if any.
+	Code int `json:"code,omitempty"`
+
+	// ErrorCode holds the application error code of the error, if any.
+	ErrorCode string `json:"errorCode,omitempty"`
+
+	// Fields holds the fields of the error stack, as returned by
+	// errgo.SafeFields.
+	Fields map[string]interface{} `json:"fields,omitempty"`
+
+	// Context holds the context values recorded in the error stack.
+	Context map[string]string `json:"context,omitempty"`
+
+	// Stack holds the lines of the error stack with sensitive data
+	// redacted, as returned by errgo.SafeErrorStack.
+	Stack []string `json:"stack"`
+
+	// Err holds the error itself.
+	Err error `json:"-"`
+}
+
+// NewEvent returns the event for err reported at the given time.
+func NewEvent(err error, t time.Time) *Event {
+	e := &Event{
+		Time:        t,
+		Fingerprint: Fingerprint(err),
+		Type:        fmt.Sprintf("%T", errgo.Cause(err)),
+		Message:     errgo.SafeError(err),
+		Code:        errgo.Code(err),
+		Context:     errgo.ContextValues(err),
+		Stack:       strings.Split(errgo.SafeErrorStack(err), "\n"),
+		Err:         err,
+	}
+	if c := errgo.ErrorCodeOf(err); c != nil {
+		e.ErrorCode = c.Code
+	}
+	for _, field := range errgo.SafeFields(err) {
+		if e.Fields == nil {
+			e.Fields = make(map[string]interface{})
+		}
+		e.Fields[field.Key] = field.Value
+	}
+	return e
+}
+
+type locationer interface {
+	Location() (file, function string, line int)
+}
+
+type wrapper interface {
+	Underlying() error
+}
+
+// Fingerprint returns a hash identifying the errors which have the same
+// Cause type and originated at the same location, so that they can be
+// grouped together. The origin is the location of the Cause of err if it
+// has one, and otherwise the location of the original error of the
+// stack of err.
+func Fingerprint(err error) string {
+	if err == nil {
+		return ""
+	}
+	cause := errgo.Cause(err)
+	file, function, line := origin(err, cause)
+	h := sha256.New()
+	fmt.Fprintf(h, "%T\n%s\n%s\n%d", cause, file, function, line)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// origin returns the location at which err originated.
+func origin(err, cause error) (file, function string, line int) {
+	if cause, ok := cause.(locationer); ok {
+		if file, function, line := cause.Location(); file != "" {
+			return file, function, line
+		}
+	}
+	for ; err != nil; err = underlying(err) {
+		if err, ok := err.(locationer); ok {
+			if f, fn, l := err.Location(); f != "" {
+				file, function, line = f, fn, l
+			}
+		}
+	}
+	return file, function, line
+}
+
+// underlying returns the previous error in the stack of err, or nil.
+func underlying(err error) error {
+	if err, ok := err.(wrapper); ok {
+		return err.Underlying()
+	}
+	return nil
+}
+
+// Sink receives reported errors.
+type Sink interface {
+	// Send delivers event. It is called by a single goroutine at a
+	// time.
+	Send(ctx context.Context, event *Event) error
+}
+
+// Options holds the configuration of a Reporter.
+type Options struct {
+	// Sinks holds the sinks the errors are delivered to. More may be
+	// added with Reporter.Register.
+	Sinks []Sink
+
+	// SampleRate holds the fraction of errors, between 0 and 1, which
+	// are reported. If it is zero, all errors are reported.
+	SampleRate float64
+
+	// RateLimit holds the maximum number of errors with the same
+	// fingerprint reported in each RateInterval. If it is zero, there
+	// is no limit.
+	RateLimit int
+
+	// RateInterval holds the interval over which RateLimit applies. If
+	// it is zero, it is one minute.
+	RateInterval time.Duration
+
+	// BufferSize holds the number of errors waiting for delivery
+	// beyond which new errors are dropped. If it is zero, it is 100.
+	BufferSize int
+
+	// OnError is called with any error returned by a sink.
+	OnError func(error)
+
+	// Now returns the current time. If it is nil, time.Now is used.
+	Now func() time.Time
+
+	// Rand returns the random numbers in [0, 1) used for sampling. If
+	// it is nil, math/rand is used.
+	Rand func() float64
+}
+
+// delivery holds an event waiting for delivery.
+type delivery struct {
+	ctx   context.Context
+	event *Event
+}
+
+// window holds the number of errors reported for a fingerprint in the
+// current rate limiting interval.
+type window struct {
+	start time.Time
+	count int
+}
+
+// waiter holds a call to Flush waiting for the delivery of the events
+// queued before it.
+type waiter struct {
+	queued uint64
+	done   chan struct{}
+}
+
+// Reporter delivers reported errors to its sinks in the background.
+type Reporter struct {
+	opts  Options
+	queue chan delivery
+
+	mu      sync.Mutex
+	sinks   []Sink
+	windows map[string]*window
+	// swept holds the time expired windows were last evicted.
+	swept time.Time
+	// queued and delivered hold the number of events queued and
+	// delivered so far.
+	queued    uint64
+	delivered uint64
+	waiters   []waiter
+	dropped   int
+	closed    bool
+
+	start sync.Once
+}
+
+// New returns a new Reporter with the given options.
+func New(opts Options) *Reporter {
+	if opts.RateInterval == 0 {
+		opts.RateInterval = time.Minute
+	}
+	if opts.BufferSize == 0 {
+		opts.BufferSize = 100
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if opts.Rand == nil {
+		opts.Rand = rand.Float64
+	}
+	return &Reporter{
+		opts:    opts,
+		queue:   make(chan delivery, opts.BufferSize),
+		sinks:   append([]Sink(nil), opts.Sinks...),
+		windows: make(map[string]*window),
+	}
+}
+
+// Register adds sinks to the sinks of r.
+func (r *Reporter) Register(sinks ...Sink) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sinks = append(r.sinks, sinks...)
+}
+
+// Report queues err for delivery to the sinks of r, unless it is nil, it
+// is not sampled, it exceeds the rate limit for its fingerprint, the
+// buffer is full or r is closed. The values of ctx are kept for delivery,
+// but its cancellation is not.
+func (r *Reporter) Report(ctx context.Context, err error) {
+	if err == nil {
+		return
+	}
+	if r.opts.SampleRate > 0 && r.opts.Rand() >= r.opts.SampleRate {
+		return
+	}
+	event := NewEvent(err, r.opts.Now())
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || !r.allow(event) {
+		return
+	}
+	r.start.Do(func() {
+		go r.run()
+	})
+	select {
+	case r.queue <- delivery{context.WithoutCancel(ctx), event}:
+		r.queued++
+	default:
+		r.dropped++
+	}
+}
+
+// allow reports whether event is within the rate limit, counting it if
+// so. It must be called with r.mu held.
+func (r *Reporter) allow(event *Event) bool {
+	if r.opts.RateLimit <= 0 {
+		return true
+	}
+	if event.Time.Sub(r.swept) >= r.opts.RateInterval {
+		// Evict the expired windows, so that the fingerprints of past
+		// errors are not kept forever.
+		for fingerprint, w := range r.windows {
+			if event.Time.Sub(w.start) >= r.opts.RateInterval {
+				delete(r.windows, fingerprint)
+			}
+		}
+		r.swept = event.Time
+	}
+	w := r.windows[event.Fingerprint]
+	if w == nil || event.Time.Sub(w.start) >= r.opts.RateInterval {
+		w = &window{start: event.Time}
+		r.windows[event.Fingerprint] = w
+	}
+	if w.count >= r.opts.RateLimit {
+		return false
+	}
+	w.count++
+	return true
+}
+
+// Dropped returns the number of errors dropped because the buffer was
+// full.
+func (r *Reporter) Dropped() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dropped
+}
+
+// run delivers the queued events until the queue is closed.
+func (r *Reporter) run() {
+	for d := range r.queue {
+		r.mu.Lock()
+		sinks := r.sinks
+		r.mu.Unlock()
+		for _, sink := range sinks {
+			if err := sink.Send(d.ctx, d.event); err != nil && r.opts.OnError != nil {
+				r.opts.OnError(errgo.Annotatef(err, "cannot send error report to %T", sink))
+			}
+		}
+		r.mu.Lock()
+		r.delivered++
+		waiters := r.waiters[:0]
+		for _, w := range r.waiters {
+			if w.queued <= r.delivered {
+				close(w.done)
+			} else {
+				waiters = append(waiters, w)
+			}
+		}
+		r.waiters = waiters
+		r.mu.Unlock()
+	}
+}
+
+// Flush waits until the errors queued before the call have been
+// delivered, or ctx is done. Errors queued during the call are not waited
+// for.
+func (r *Reporter) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	if r.delivered >= r.queued {
+		r.mu.Unlock()
+		return nil
+	}
+	w := waiter{r.queued, make(chan struct{})}
+	r.waiters = append(r.waiters, w)
+	r.mu.Unlock()
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return errgo.Trace(ctx.Err())
+	}
+}
+
+// Close stops r accepting errors and waits for those already queued to be
+// delivered, as for Flush. If ctx is done first, they are still delivered
+// in the background, and Close may be called again to wait for them.
+func (r *Reporter) Close(ctx context.Context) error {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		// Report does not queue events once r is closed, and the
+		// goroutine delivering them stops once the queue is drained.
+		close(r.queue)
+	}
+	r.mu.Unlock()
+	return errgo.Trace(r.Flush(ctx))
+}
+
+// defaultReporter is used by the package level functions.
+var defaultReporter = New(Options{})
+
+// Register adds sinks to the default reporter.
+func Register(sinks ...Sink) {
+	defaultReporter.Register(sinks...)
+}
+
+// Report reports err to the sinks of the default reporter, as for
+// Reporter.Report.
+func Report(ctx context.Context, err error) {
+	defaultReporter.Report(ctx, err)
+}
+
+// Flush waits until the errors reported to the default reporter have been
+// delivered, as for Reporter.Flush.
+func Flush(ctx context.Context) error {
+	return defaultReporter.Flush(ctx)
+}
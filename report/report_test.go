@@ -0,0 +1,431 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package report_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/hifx/errgo"
+	"github.com/hifx/errgo/report"
+)
+
+func Test(t *testing.T) {
+	gc.TestingT(t)
+}
+
+type reportSuite struct{}
+
+var _ = gc.Suite(&reportSuite{})
+
+// recordingSink is a report.Sink which records the events sent to it.
+type recordingSink struct {
+	mu     sync.Mutex
+	events []*report.Event
+}
+
+func (s *recordingSink) Send(ctx context.Context, event *report.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *recordingSink) messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var messages []string
+	for _, event := range s.events {
+		messages = append(messages, event.Message)
+	}
+	return messages
+}
+
+// blockingSink is a report.Sink which signals each send on started and
+// then waits for release.
+type blockingSink struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingSink() *blockingSink {
+	return &blockingSink{make(chan struct{}, 10), make(chan struct{})}
+}
+
+func (s *blockingSink) Send(ctx context.Context, event *report.Event) error {
+	s.started <- struct{}{}
+	<-s.release
+	return nil
+}
+
+// errorAt returns a new error created at a single location, so that all
+// the errors it returns have the same fingerprint.
+func errorAt(message string) error {
+	return errgo.New(message)
+}
+
+func (*reportSuite) TestNewEvent(c *gc.C) {
+	t := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	err := errgo.AnnotateWith(errgo.New("first"), "loading", errgo.F("user", 42))
+	err = errgo.Wrap(err, errgo.NotFoundf("user %s", errgo.Sensitive("jo@example.com")))
+	event := report.NewEvent(err, t)
+	c.Assert(event.Time, gc.Equals, t)
+	c.Assert(event.Fingerprint, gc.Equals, report.Fingerprint(err))
+	c.Assert(event.Type, gc.Equals, "*errgo.Err")
+	c.Assert(event.Message, gc.Equals, "user [REDACTED]")
+	c.Assert(event.Code, gc.Equals, 404)
+	c.Assert(event.Fields, jc.DeepEquals, map[string]interface{}{"user": 42})
+	c.Assert(event.Stack, jc.DeepEquals, strings.Split(errgo.SafeErrorStack(err), "\n"))
+	c.Assert(strings.Join(event.Stack, "\n"), gc.Not(jc.Contains), "jo@example.com")
+	c.Assert(event.Err, gc.Equals, err)
+}
+
+func (*reportSuite) TestFingerprint(c *gc.C) {
+	first := errorAt("first")
+	second := errgo.Annotate(errorAt("second"), "more context")
+	other := errgo.New("first")
+	c.Assert(report.Fingerprint(first), gc.HasLen, 16)
+	c.Assert(report.Fingerprint(first), gc.Equals, report.Fingerprint(second))
+	c.Assert(report.Fingerprint(first), gc.Not(gc.Equals), report.Fingerprint(other))
+	c.Assert(report.Fingerprint(io.EOF), gc.Not(gc.Equals), report.Fingerprint(first))
+	c.Assert(report.Fingerprint(nil), gc.Equals, "")
+
+	// A wrapped cause is grouped by the location of the cause.
+	wrapped := errgo.Wrap(errorAt("first"), errorAt("cause"))
+	c.Assert(report.Fingerprint(wrapped), gc.Equals, report.Fingerprint(first))
+}
+
+func (*reportSuite) TestReport(c *gc.C) {
+	sink := &recordingSink{}
+	r := report.New(report.Options{Sinks: []report.Sink{sink}})
+	other := &recordingSink{}
+	r.Register(other)
+	r.Report(context.Background(), errgo.New("first"))
+	r.Report(context.Background(), nil)
+	r.Report(context.Background(), errgo.New("second"))
+	c.Assert(r.Flush(context.Background()), gc.IsNil)
+	c.Assert(sink.messages(), jc.DeepEquals, []string{"first", "second"})
+	c.Assert(other.messages(), jc.DeepEquals, []string{"first", "second"})
+	c.Assert(r.Close(context.Background()), gc.IsNil)
+}
+
+func (*reportSuite) TestReportKeepsContextValues(c *gc.C) {
+	type key struct{}
+	var got interface{}
+	sink := sinkFunc(func(ctx context.Context, event *report.Event) error {
+		got = ctx.Value(key{})
+		return ctx.Err()
+	})
+	r := report.New(report.Options{Sinks: []report.Sink{sink}})
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "value"))
+	cancel()
+	r.Report(ctx, errgo.New("first"))
+	c.Assert(r.Flush(context.Background()), gc.IsNil)
+	c.Assert(got, gc.Equals, "value")
+}
+
+type sinkFunc func(ctx context.Context, event *report.Event) error
+
+func (f sinkFunc) Send(ctx context.Context, event *report.Event) error {
+	return f(ctx, event)
+}
+
+func (*reportSuite) TestSampling(c *gc.C) {
+	sink := &recordingSink{}
+	random := []float64{0.1, 0.7, 0.4, 0.5}
+	r := report.New(report.Options{
+		Sinks:      []report.Sink{sink},
+		SampleRate: 0.5,
+		Rand: func() float64 {
+			f := random[0]
+			random = random[1:]
+			return f
+		},
+	})
+	for _, message := range []string{"one", "two", "three", "four"} {
+		r.Report(context.Background(), errgo.New(message))
+	}
+	c.Assert(r.Flush(context.Background()), gc.IsNil)
+	c.Assert(sink.messages(), jc.DeepEquals, []string{"one", "three"})
+}
+
+func (*reportSuite) TestRateLimit(c *gc.C) {
+	sink := &recordingSink{}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	r := report.New(report.Options{
+		Sinks:        []report.Sink{sink},
+		RateLimit:    2,
+		RateInterval: time.Minute,
+		Now:          func() time.Time { return now },
+	})
+	for _, message := range []string{"one", "two", "three"} {
+		r.Report(context.Background(), errorAt(message))
+	}
+	r.Report(context.Background(), errgo.New("other"))
+	now = now.Add(time.Minute)
+	r.Report(context.Background(), errorAt("four"))
+	c.Assert(r.Flush(context.Background()), gc.IsNil)
+	c.Assert(sink.messages(), jc.DeepEquals, []string{"one", "two", "other", "four"})
+}
+
+func (*reportSuite) TestRateLimitEvictsWindows(c *gc.C) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	r := report.New(report.Options{
+		Sinks:        []report.Sink{&recordingSink{}},
+		RateLimit:    1,
+		RateInterval: time.Minute,
+		Now:          func() time.Time { return now },
+	})
+	r.Report(context.Background(), errgo.New("one"))
+	r.Report(context.Background(), errgo.New("two"))
+	c.Assert(report.Windows(r), gc.Equals, 2)
+	now = now.Add(30 * time.Second)
+	r.Report(context.Background(), errgo.New("three"))
+	c.Assert(report.Windows(r), gc.Equals, 3)
+	now = now.Add(45 * time.Second)
+	r.Report(context.Background(), errgo.New("four"))
+	c.Assert(report.Windows(r), gc.Equals, 2)
+	c.Assert(r.Close(context.Background()), gc.IsNil)
+}
+
+func (*reportSuite) TestBufferFull(c *gc.C) {
+	sink := newBlockingSink()
+	r := report.New(report.Options{
+		Sinks:      []report.Sink{sink},
+		BufferSize: 1,
+	})
+	r.Report(context.Background(), errgo.New("one"))
+	<-sink.started
+	r.Report(context.Background(), errgo.New("two"))
+	r.Report(context.Background(), errgo.New("three"))
+	c.Assert(r.Dropped(), gc.Equals, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := r.Flush(ctx)
+	c.Assert(errgo.Cause(err), gc.Equals, context.DeadlineExceeded)
+
+	close(sink.release)
+	c.Assert(r.Close(context.Background()), gc.IsNil)
+	c.Assert(sink.started, gc.HasLen, 1)
+}
+
+func (*reportSuite) TestFlushUnderLoad(c *gc.C) {
+	sink := newBlockingSink()
+	r := report.New(report.Options{Sinks: []report.Sink{sink}})
+	r.Report(context.Background(), errgo.New("one"))
+	<-sink.started
+	flushed := make(chan error)
+	go func() {
+		flushed <- r.Flush(context.Background())
+	}()
+	// Wait until the Flush call is waiting.
+	for report.Waiters(r) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	r.Report(context.Background(), errgo.New("two"))
+
+	// Flush returns once "one" is delivered, although "two" is not.
+	sink.release <- struct{}{}
+	c.Assert(<-flushed, gc.IsNil)
+	<-sink.started
+	close(sink.release)
+	c.Assert(r.Close(context.Background()), gc.IsNil)
+}
+
+func (*reportSuite) TestClose(c *gc.C) {
+	sink := &recordingSink{}
+	r := report.New(report.Options{Sinks: []report.Sink{sink}})
+	r.Report(context.Background(), errgo.New("one"))
+	c.Assert(r.Close(context.Background()), gc.IsNil)
+	r.Report(context.Background(), errgo.New("two"))
+	c.Assert(r.Close(context.Background()), gc.IsNil)
+	c.Assert(sink.messages(), jc.DeepEquals, []string{"one"})
+}
+
+func (*reportSuite) TestCloseTimeout(c *gc.C) {
+	sink := newBlockingSink()
+	r := report.New(report.Options{Sinks: []report.Sink{sink}})
+	r.Report(context.Background(), errgo.New("one"))
+	r.Report(context.Background(), errgo.New("two"))
+	<-sink.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := r.Close(ctx)
+	c.Assert(errgo.Cause(err), gc.Equals, context.DeadlineExceeded)
+	r.Report(context.Background(), errgo.New("three"))
+
+	// The queued errors are still delivered, and Close waits for them.
+	close(sink.release)
+	c.Assert(r.Close(context.Background()), gc.IsNil)
+	c.Assert(sink.started, gc.HasLen, 1)
+}
+
+func (*reportSuite) TestLogSink(c *gc.C) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	err := errgo.New("first")
+	event := report.NewEvent(err, time.Now())
+	c.Assert(report.NewLogSink(logger).Send(context.Background(), event), gc.IsNil)
+	var record map[string]interface{}
+	c.Assert(json.Unmarshal(buf.Bytes(), &record), gc.IsNil)
+	c.Assert(record["level"], gc.Equals, "ERROR")
+	c.Assert(record["msg"], gc.Equals, "first")
+	c.Assert(record["fingerprint"], gc.Equals, event.Fingerprint)
+	c.Assert(record["err"].(map[string]interface{})["message"], gc.Equals, "first")
+}
+
+func (*reportSuite) TestFileSink(c *gc.C) {
+	var buf bytes.Buffer
+	sink := report.NewFileSink(&buf)
+	t := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	c.Assert(sink.Send(context.Background(), report.NewEvent(errgo.New("first"), t)), gc.IsNil)
+	c.Assert(sink.Send(context.Background(), report.NewEvent(errgo.New("second"), t)), gc.IsNil)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	c.Assert(lines, gc.HasLen, 2)
+	var event report.Event
+	c.Assert(json.Unmarshal([]byte(lines[1]), &event), gc.IsNil)
+	c.Assert(event.Message, gc.Equals, "second")
+	c.Assert(event.Time.Equal(t), jc.IsTrue)
+}
+
+func (*reportSuite) TestWebhookSink(c *gc.C) {
+	var received []report.Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var event report.Event
+		if err := json.NewDecoder(req.Body).Decode(&event); err != nil || req.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		received = append(received, event)
+	}))
+	defer server.Close()
+
+	var errs []error
+	r := report.New(report.Options{
+		Sinks:   []report.Sink{report.NewWebhookSink(server.URL, nil)},
+		OnError: func(err error) { errs = append(errs, err) },
+	})
+	err := errgo.TraceWith(errgo.NotFoundf("user"), errgo.F("user", "jo"))
+	r.Report(context.Background(), err)
+	c.Assert(r.Close(context.Background()), gc.IsNil)
+	c.Assert(errs, gc.HasLen, 0)
+	c.Assert(received, gc.HasLen, 1)
+	c.Assert(received[0].Message, gc.Equals, "user")
+	c.Assert(received[0].Code, gc.Equals, 404)
+	c.Assert(received[0].Fingerprint, gc.Equals, report.Fingerprint(err))
+	c.Assert(received[0].Fields, jc.DeepEquals, map[string]interface{}{"user": "jo"})
+}
+
+func (*reportSuite) TestWebhookSinkError(c *gc.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "oops", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	var errs []error
+	r := report.New(report.Options{
+		Sinks:   []report.Sink{report.NewWebhookSink(server.URL, nil)},
+		OnError: func(err error) { errs = append(errs, err) },
+	})
+	r.Report(context.Background(), errgo.New("first"))
+	c.Assert(r.Close(context.Background()), gc.IsNil)
+	c.Assert(errs, gc.HasLen, 1)
+	c.Assert(errs[0], gc.ErrorMatches, `cannot send error report to \*report.WebhookSink: cannot post to .*: 500 Internal Server Error`)
+}
+
+func (*reportSuite) TestSentrySink(c *gc.C) {
+	var path, auth, contentType string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		path = req.URL.Path
+		auth = req.Header.Get("X-Sentry-Auth")
+		contentType = req.Header.Get("Content-Type")
+		body, _ = io.ReadAll(req.Body)
+	}))
+	defer server.Close()
+
+	dsn := strings.Replace(server.URL, "://", "://public@", 1) + "/sentry/42"
+	sink, err := report.NewSentrySink(dsn, nil)
+	c.Assert(err, gc.IsNil)
+	e := errgo.Annotate(errgo.NotFoundf("user"), "loading")
+	c.Assert(sink.Send(context.Background(), report.NewEvent(e, time.Now())), gc.IsNil)
+	c.Assert(path, gc.Equals, "/sentry/api/42/envelope/")
+	c.Assert(auth, gc.Matches, "Sentry sentry_version=7, .*sentry_key=public")
+	c.Assert(contentType, gc.Equals, "application/x-sentry-envelope")
+
+	lines := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+	c.Assert(lines, gc.HasLen, 3)
+	var header map[string]string
+	c.Assert(json.Unmarshal([]byte(lines[0]), &header), gc.IsNil)
+	c.Assert(header["dsn"], gc.Equals, dsn)
+	c.Assert(header["event_id"], gc.HasLen, 32)
+	var item struct {
+		Type   string `json:"type"`
+		Length int    `json:"length"`
+	}
+	c.Assert(json.Unmarshal([]byte(lines[1]), &item), gc.IsNil)
+	c.Assert(item.Type, gc.Equals, "event")
+	c.Assert(item.Length, gc.Equals, len(lines[2]))
+
+	var payload struct {
+		EventID     string            `json:"event_id"`
+		Level       string            `json:"level"`
+		Fingerprint []string          `json:"fingerprint"`
+		Tags        map[string]string `json:"tags"`
+		Exception   struct {
+			Values []struct {
+				Type       string `json:"type"`
+				Value      string `json:"value"`
+				Stacktrace struct {
+					Frames []struct {
+						Function string `json:"function"`
+						Lineno   int    `json:"lineno"`
+					} `json:"frames"`
+				} `json:"stacktrace"`
+			} `json:"values"`
+		} `json:"exception"`
+	}
+	c.Assert(json.Unmarshal([]byte(lines[2]), &payload), gc.IsNil)
+	c.Assert(payload.EventID, gc.Equals, header["event_id"])
+	c.Assert(payload.Level, gc.Equals, "error")
+	c.Assert(payload.Fingerprint, jc.DeepEquals, []string{report.Fingerprint(e)})
+	c.Assert(payload.Tags, jc.DeepEquals, map[string]string{"code": "404"})
+	c.Assert(payload.Exception.Values, gc.HasLen, 1)
+	exception := payload.Exception.Values[0]
+	c.Assert(exception.Type, gc.Equals, "*errgo.Err")
+	c.Assert(exception.Value, gc.Equals, "loading: user")
+	frames := exception.Stacktrace.Frames
+	c.Assert(frames, gc.HasLen, 2)
+	c.Assert(frames[0].Function, gc.Equals, "github.com/hifx/errgo/report_test.(*reportSuite).TestSentrySink")
+	c.Assert(frames[0].Lineno, gc.Equals, frames[1].Lineno)
+}
+
+func (*reportSuite) TestNewSentrySinkInvalidDSN(c *gc.C) {
+	_, err := report.NewSentrySink("https://sentry.example.com/42", nil)
+	c.Assert(err, gc.ErrorMatches, `invalid Sentry DSN "https://sentry.example.com/42": missing key or project`)
+	_, err = report.NewSentrySink("https://public@sentry.example.com/", nil)
+	c.Assert(err, gc.ErrorMatches, `invalid Sentry DSN .*: missing key or project`)
+}
+
+func (*reportSuite) TestDefaultReporter(c *gc.C) {
+	sink := &recordingSink{}
+	report.Register(sink)
+	report.Report(context.Background(), errgo.New("first"))
+	c.Assert(report.Flush(context.Background()), gc.IsNil)
+	c.Assert(sink.messages(), jc.DeepEquals, []string{"first"})
+}
@@ -0,0 +1,257 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package report
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hifx/errgo"
+)
+
+// LogSink is a Sink which logs errors to a slog.Logger.
+type LogSink struct {
+	logger *slog.Logger
+}
+
+// NewLogSink returns a Sink which logs each error to logger at the error
+// level, with the error itself under the key "err" and its fingerprint
+// under the key "fingerprint".
+func NewLogSink(logger *slog.Logger) *LogSink {
+	return &LogSink{logger}
+}
+
+// Send implements Sink.Send.
+func (s *LogSink) Send(ctx context.Context, event *Event) error {
+	s.logger.ErrorContext(ctx, event.Message, "err", event.Err, "fingerprint", event.Fingerprint)
+	return nil
+}
+
+// FileSink is a Sink which writes errors to a file as JSON, one event per
+// line.
+type FileSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewFileSink returns a Sink which writes each event to w as a line of
+// JSON.
+func NewFileSink(w io.Writer) *FileSink {
+	return &FileSink{w: w}
+}
+
+// Send implements Sink.Send.
+func (s *FileSink) Send(ctx context.Context, event *Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return errgo.Trace(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.w.Write(append(data, '\n')); err != nil {
+		return errgo.Trace(err)
+	}
+	return nil
+}
+
+// WebhookSink is a Sink which posts errors to an HTTP endpoint.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookSink returns a Sink which posts each event to url as JSON,
+// using client, or http.DefaultClient if it is nil.
+func NewWebhookSink(url string, client *http.Client) *WebhookSink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &WebhookSink{url, client}
+}
+
+// Send implements Sink.Send.
+func (s *WebhookSink) Send(ctx context.Context, event *Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return errgo.Trace(err)
+	}
+	return post(ctx, s.client, s.url, "application/json", data, nil)
+}
+
+// post posts body to url, returning an error if the response does not
+// have a 2xx status.
+func post(ctx context.Context, client *http.Client, url, contentType string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return errgo.Trace(err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := client.Do(req)
+	if err != nil {
+		return errgo.Trace(err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errgo.Errorf("cannot post to %s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+// SentrySink is a Sink which sends errors to Sentry, or any service which
+// accepts Sentry envelopes.
+type SentrySink struct {
+	dsn      string
+	endpoint string
+	auth     string
+	client   *http.Client
+}
+
+// NewSentrySink returns a Sink which sends each event as a Sentry envelope
+// to the project identified by dsn, such as
+// "https://public@sentry.example.com/42", using client, or
+// http.DefaultClient if it is nil.
+func NewSentrySink(dsn string, client *http.Client) (*SentrySink, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, errgo.Annotatef(err, "invalid Sentry DSN")
+	}
+	prefix, project := "", strings.TrimPrefix(u.Path, "/")
+	if i := strings.LastIndex(project, "/"); i >= 0 {
+		prefix, project = project[:i+1], project[i+1:]
+	}
+	if u.User == nil || u.User.Username() == "" || project == "" {
+		return nil, errgo.Errorf("invalid Sentry DSN %q: missing key or project", dsn)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &SentrySink{
+		dsn:      dsn,
+		endpoint: fmt.Sprintf("%s://%s/%sapi/%s/envelope/", u.Scheme, u.Host, prefix, project),
+		auth:     "Sentry sentry_version=7, sentry_client=errgo-report/1.0, sentry_key=" + u.User.Username(),
+		client:   client,
+	}, nil
+}
+
+// Send implements Sink.Send.
+func (s *SentrySink) Send(ctx context.Context, event *Event) error {
+	var buf bytes.Buffer
+	if err := s.WriteEnvelope(&buf, event); err != nil {
+		return errgo.Trace(err)
+	}
+	header := http.Header{"X-Sentry-Auth": {s.auth}}
+	return post(ctx, s.client, s.endpoint, "application/x-sentry-envelope", buf.Bytes(), header)
+}
+
+// sentryEvent holds the payload of a Sentry envelope.
+type sentryEvent struct {
+	EventID     string                 `json:"event_id"`
+	Timestamp   string                 `json:"timestamp"`
+	Level       string                 `json:"level"`
+	Platform    string                 `json:"platform"`
+	Fingerprint []string               `json:"fingerprint"`
+	Exception   sentryExceptions       `json:"exception"`
+	Tags        map[string]string      `json:"tags,omitempty"`
+	Extra       map[string]interface{} `json:"extra,omitempty"`
+}
+
+type sentryExceptions struct {
+	Values []sentryException `json:"values"`
+}
+
+type sentryException struct {
+	Type       string            `json:"type"`
+	Value      string            `json:"value"`
+	Stacktrace *sentryStacktrace `json:"stacktrace,omitempty"`
+}
+
+type sentryStacktrace struct {
+	Frames []sentryFrame `json:"frames"`
+}
+
+type sentryFrame struct {
+	Filename string `json:"filename"`
+	Function string `json:"function,omitempty"`
+	Lineno   int    `json:"lineno"`
+}
+
+// WriteEnvelope writes event to w as a Sentry envelope holding a single
+// error event. The frames of its stack trace are the locations in the
+// error stack, from the most recent to the original one, so that Sentry
+// shows the origin of the error as the crashing frame.
+func (s *SentrySink) WriteEnvelope(w io.Writer, event *Event) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return errgo.Trace(err)
+	}
+	payload := sentryEvent{
+		EventID:     hex.EncodeToString(id),
+		Timestamp:   event.Time.UTC().Format(time.RFC3339Nano),
+		Level:       "error",
+		Platform:    "go",
+		Fingerprint: []string{event.Fingerprint},
+		Exception: sentryExceptions{[]sentryException{{
+			Type:       event.Type,
+			Value:      event.Message,
+			Stacktrace: sentryStack(event.Err),
+		}}},
+		Tags:  make(map[string]string),
+		Extra: event.Fields,
+	}
+	for name, value := range event.Context {
+		payload.Tags[name] = value
+	}
+	if event.Code != 0 {
+		payload.Tags["code"] = fmt.Sprint(event.Code)
+	}
+	if event.ErrorCode != "" {
+		payload.Tags["errorCode"] = event.ErrorCode
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return errgo.Trace(err)
+	}
+	header, err := json.Marshal(map[string]string{
+		"event_id": payload.EventID,
+		"sent_at":  time.Now().UTC().Format(time.RFC3339Nano),
+		"dsn":      s.dsn,
+	})
+	if err != nil {
+		return errgo.Trace(err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n{\"type\":\"event\",\"length\":%d}\n%s\n", header, len(data), data)
+	return errgo.Trace(err)
+}
+
+// sentryStack returns the Sentry stack trace of the locations in the error
+// stack of err, or nil if there are none.
+func sentryStack(err error) *sentryStacktrace {
+	var frames []sentryFrame
+	for ; err != nil; err = underlying(err) {
+		if err, ok := err.(locationer); ok {
+			if file, function, line := err.Location(); file != "" {
+				frames = append(frames, sentryFrame{file, function, line})
+			}
+		}
+	}
+	if frames == nil {
+		return nil
+	}
+	return &sentryStacktrace{frames}
+}
@@ -0,0 +1,167 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// retryClass records whether an error is worth retrying.
+type retryClass int8
+
+const (
+	// retryUnknown leaves the classification to the rest of the stack.
+	retryUnknown retryClass = iota
+	retryRetryable
+	retryPermanent
+)
+
+// retryableCodes holds the HTTP response codes of the errors which are
+// retryable unless classified otherwise, such as those returned by
+// ServiceUnavailablef, TooManyRequestsf and GatewayTimeoutf.
+var retryableCodes = map[int]bool{
+	http.StatusRequestTimeout:     true,
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// Retryable returns a new error in the stack of other, keeping its cause,
+// which classifies the error as worth retrying. The location of the call
+// is recorded. Retryable returns nil if other is nil.
+//
+// For example:
+//   if err := client.Call(req); err != nil {
+//       return errgo.Retryable(err)
+//   }
+//
+func Retryable(other error) error {
+	return withRetry(other, retryRetryable, 0)
+}
+
+// Permanent returns a new error in the stack of other, keeping its cause,
+// which classifies the error as not worth retrying, whatever the errors
+// beneath it. The location of the call is recorded. Permanent returns nil
+// if other is nil.
+func Permanent(other error) error {
+	return withRetry(other, retryPermanent, 0)
+}
+
+// WithRetryAfter is like Retryable, but also records that a retry should
+// not be attempted before the given delay, such as one sent by a server
+// in a Retry-After header.
+func WithRetryAfter(other error, delay time.Duration) error {
+	return withRetry(other, retryRetryable, delay)
+}
+
+// withRetry is a helper to construct the errors returned by Retryable,
+// Permanent and WithRetryAfter.
+func withRetry(other error, class retryClass, delay time.Duration) error {
+	if other == nil {
+		return nil
+	}
+	err := &Err{
+		previous:   other,
+		cause:      Cause(other),
+		retry:      class,
+		retryAfter: delay,
+	}
+	err.SetLocation(2)
+	return err
+}
+
+// SetRetryable classifies the error as worth retrying or not.
+func (e *Err) SetRetryable(retryable bool) {
+	if retryable {
+		e.retry = retryRetryable
+	} else {
+		e.retry = retryPermanent
+	}
+}
+
+// SetRetryAfter classifies the error as worth retrying after the given
+// delay.
+func (e *Err) SetRetryAfter(delay time.Duration) {
+	e.retry = retryRetryable
+	e.retryAfter = delay
+}
+
+// Retryable returns whether this entry in the error stack was classified
+// as worth retrying, and whether it was classified at all.
+func (e *Err) Retryable() (retryable, ok bool) {
+	return e.retry == retryRetryable, e.retry != retryUnknown
+}
+
+// RetryAfter returns the delay before a retry recorded for this entry in
+// the error stack, if any.
+func (e *Err) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+type retryClassifier interface {
+	Retryable() (retryable, ok bool)
+}
+
+type retryAfterer interface {
+	RetryAfter() time.Duration
+}
+
+var (
+	_ retryClassifier = (*Err)(nil)
+	_ retryAfterer    = (*Err)(nil)
+)
+
+// IsRetryable reports whether err is worth retrying. The stack of err is
+// searched as for Code, and the most recent classification wins: that
+// made by Retryable, Permanent, WithRetryAfter or the Err methods, or by a
+// net.Error which reports a timeout or a temporary condition. The context
+// errors context.Canceled and context.DeadlineExceeded are permanent,
+// although the latter is a net.Error reporting a timeout, as retrying
+// with the same context cannot succeed. Otherwise errors with the HTTP
+// response codes 408, 429, 502, 503 and 504 are retryable, and all others
+// are not.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	retryable := false
+	found := findError(err, func(e error) bool {
+		if e, ok := e.(retryClassifier); ok {
+			if r, ok := e.Retryable(); ok {
+				retryable = r
+				return true
+			}
+		}
+		if e == context.Canceled || e == context.DeadlineExceeded {
+			retryable = false
+			return true
+		}
+		if e, ok := e.(net.Error); ok && (e.Timeout() || e.Temporary()) {
+			retryable = true
+			return true
+		}
+		return false
+	})
+	if found != nil {
+		return retryable
+	}
+	return retryableCodes[Code(err)]
+}
+
+// RetryAfter returns the most recent delay before a retry recorded in the
+// stack of err, searched as for Code, or 0 if there is none.
+func RetryAfter(err error) time.Duration {
+	found := findError(err, func(e error) bool {
+		e1, ok := e.(retryAfterer)
+		return ok && e1.RetryAfter() > 0
+	})
+	if found == nil {
+		return 0
+	}
+	return found.(retryAfterer).RetryAfter()
+}
@@ -0,0 +1,183 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+// Package retry calls functions again when they fail with errors that
+// errgo classifies as retryable, keeping the history of the failed
+// attempts in the error stack of the final error.
+package retry
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"reflect"
+	"time"
+
+	"github.com/hifx/errgo"
+)
+
+// Clock provides the timers used to wait between attempts, so that tests
+// can avoid real delays.
+type Clock interface {
+	// After returns a channel which receives the current time once d
+	// has elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the Clock which uses real timers.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+// After implements Clock.After.
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Policy describes how often and how soon a failed call is retried.
+type Policy struct {
+	// MaxAttempts holds the maximum number of calls, including the
+	// first one. If it is zero or less, calls are retried until the
+	// context is done.
+	MaxAttempts int
+
+	// InitialDelay holds the delay before the first retry.
+	InitialDelay time.Duration
+
+	// MaxDelay holds the maximum delay between attempts, if it is
+	// positive. A longer delay requested by the error itself, as
+	// returned by errgo.RetryAfter, is still honoured.
+	MaxDelay time.Duration
+
+	// Multiplier holds the factor by which the delay grows after each
+	// retry. If it is zero, the delay doubles.
+	Multiplier float64
+
+	// Jitter holds the fraction of each delay, between 0 and 1, which
+	// is chosen at random, so that clients failing together do not all
+	// retry together.
+	Jitter float64
+
+	// Retryable reports whether an error is worth retrying. If it is
+	// nil, errgo.IsRetryable is used.
+	Retryable func(error) bool
+
+	// Clock provides the timers for the delays. If it is nil,
+	// SystemClock is used.
+	Clock Clock
+
+	// Rand returns the random numbers in [0, 1) used for the jitter. If
+	// it is nil, math/rand is used.
+	Rand func() float64
+}
+
+// DefaultPolicy holds a policy suitable for most calls to remote
+// services.
+var DefaultPolicy = Policy{
+	MaxAttempts:  3,
+	InitialDelay: 100 * time.Millisecond,
+	MaxDelay:     10 * time.Second,
+	Multiplier:   2,
+	Jitter:       0.2,
+}
+
+// Do calls fn until it succeeds, it fails with an error which is not
+// retryable, the attempts allowed by policy are exhausted or ctx is done,
+// waiting with exponential backoff between attempts. Each failed attempt
+// is recorded as an annotation in the stack of the returned error, so that
+// errgo.ErrorStack shows the whole retry history, while the Cause, retry
+// classification and delay of the returned error are those of the last
+// attempt. If ctx is done once an
+// attempt has failed, no more attempts are made, and the Cause of the
+// returned error is ctx.Err() instead.
+//
+// For example:
+//     err := retry.Do(ctx, retry.DefaultPolicy, func(ctx context.Context) error {
+//         return client.Call(ctx, req)
+//     })
+func Do(ctx context.Context, policy Policy, fn func(ctx context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return errgo.Trace(err)
+	}
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = errgo.IsRetryable
+	}
+	clock := policy.Clock
+	if clock == nil {
+		clock = SystemClock
+	}
+	var history error
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		if history == nil {
+			history = err
+		} else {
+			// Wrapping with the Cause keeps it the innermost error of
+			// the attempt, however err was annotated.
+			cause := errgo.Cause(err)
+			if reflect.DeepEqual(errgo.Cause(history), cause) {
+				// errgo takes a cause equal to the current one, such as
+				// one created at the same place, to be unchanged, so
+				// the earlier attempts are masked for it to replace
+				// theirs.
+				history = errgo.Mask(history)
+			}
+			history = errgo.Wrap(history, cause)
+		}
+		history = annotate(history, err, attempt)
+		if !retryable(err) || attempt == policy.MaxAttempts {
+			return history
+		}
+		if err := ctx.Err(); err != nil {
+			return errgo.Wrap(history, err)
+		}
+		select {
+		case <-clock.After(policy.delay(attempt, err)):
+		case <-ctx.Done():
+			return errgo.Wrap(history, ctx.Err())
+		}
+	}
+}
+
+// annotate returns history annotated with the failure of the given
+// attempt with err. The retry classification and delay of err are
+// recorded on the annotation, as the Cause of err does not hold those
+// made further up its stack, and those of the earlier attempts would
+// otherwise be found first.
+func annotate(history, err error, attempt int) error {
+	annotated := errgo.Annotatef(history, "attempt %d failed", attempt).(*errgo.Err)
+	if delay := errgo.RetryAfter(err); delay > 0 {
+		annotated.SetRetryAfter(delay)
+	}
+	annotated.SetRetryable(errgo.IsRetryable(err))
+	return annotated
+}
+
+// delay returns the delay before the retry following the given attempt,
+// which failed with err.
+func (p Policy) delay(attempt int, err error) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+	delay := float64(p.InitialDelay) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		random := rand.Float64
+		if p.Rand != nil {
+			random = p.Rand
+		}
+		delay -= delay * p.Jitter * random()
+	}
+	d := time.Duration(delay)
+	if after := errgo.RetryAfter(err); after > d {
+		d = after
+	}
+	return d
+}
@@ -0,0 +1,294 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/hifx/errgo"
+	"github.com/hifx/errgo/retry"
+)
+
+func Test(t *testing.T) {
+	gc.TestingT(t)
+}
+
+type retrySuite struct{}
+
+var _ = gc.Suite(&retrySuite{})
+
+// fakeClock is a retry.Clock which fires at once, recording the delays
+// requested.
+type fakeClock struct {
+	delays []time.Duration
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.delays = append(c.delays, d)
+	ch := make(chan time.Time, 1)
+	ch <- time.Time{}
+	return ch
+}
+
+// stoppedClock is a retry.Clock which never fires.
+type stoppedClock struct{}
+
+func (stoppedClock) After(d time.Duration) <-chan time.Time {
+	return nil
+}
+
+// failing returns a function which fails with the given errors in turn,
+// and then succeeds, recording the number of calls in *calls.
+func failing(calls *int, errs ...error) func(context.Context) error {
+	return func(context.Context) error {
+		*calls++
+		if *calls > len(errs) {
+			return nil
+		}
+		return errs[*calls-1]
+	}
+}
+
+func (*retrySuite) TestSuccess(c *gc.C) {
+	clock := &fakeClock{}
+	calls := 0
+	err := retry.Do(context.Background(), retry.Policy{MaxAttempts: 3, Clock: clock}, failing(&calls))
+	c.Assert(err, gc.IsNil)
+	c.Assert(calls, gc.Equals, 1)
+	c.Assert(clock.delays, gc.HasLen, 0)
+}
+
+func (*retrySuite) TestRetriesUntilSuccess(c *gc.C) {
+	clock := &fakeClock{}
+	policy := retry.Policy{
+		MaxAttempts:  5,
+		InitialDelay: 100 * time.Millisecond,
+		Clock:        clock,
+	}
+	calls := 0
+	err := retry.Do(context.Background(), policy, failing(&calls,
+		errgo.ServiceUnavailablef("down"),
+		errgo.ServiceUnavailablef("down"),
+		errgo.ServiceUnavailablef("down"),
+	))
+	c.Assert(err, gc.IsNil)
+	c.Assert(calls, gc.Equals, 4)
+	c.Assert(clock.delays, jc.DeepEquals, []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+	})
+}
+
+func (*retrySuite) TestMaxAttempts(c *gc.C) {
+	clock := &fakeClock{}
+	policy := retry.Policy{
+		MaxAttempts:  3,
+		InitialDelay: time.Second,
+		Multiplier:   3,
+		MaxDelay:     2 * time.Second,
+		Clock:        clock,
+	}
+	first := errgo.ServiceUnavailablef("first")
+	second := errgo.TooManyRequestsf("second")
+	third := errgo.GatewayTimeoutf("third")
+	calls := 0
+	err := retry.Do(context.Background(), policy, failing(&calls, first, second, third, errgo.New("never")))
+	c.Assert(calls, gc.Equals, 3)
+	c.Assert(clock.delays, jc.DeepEquals, []time.Duration{time.Second, 2 * time.Second})
+	c.Assert(err, gc.ErrorMatches, "attempt 3 failed: third")
+	c.Assert(errgo.Cause(err), gc.Equals, third)
+	c.Assert(errgo.IsGatewayTimeout(err), jc.IsTrue)
+	c.Assert(errors.Is(err, third), jc.IsTrue)
+
+	// The whole history is in the error stack.
+	stack := errgo.ErrorStack(err)
+	for _, message := range []string{
+		"first",
+		"attempt 1 failed",
+		"second",
+		"attempt 2 failed",
+		"third",
+		"attempt 3 failed",
+	} {
+		c.Check(stack, jc.Contains, message)
+	}
+	c.Assert(strings.Index(stack, "first") < strings.Index(stack, "second"), jc.IsTrue)
+}
+
+func (*retrySuite) TestAnnotatedAttempts(c *gc.C) {
+	clock := &fakeClock{}
+	first := errgo.ServiceUnavailablef("first")
+	second := errgo.TooManyRequestsf("second")
+	third := errgo.GatewayTimeoutf("third")
+	calls := 0
+	err := retry.Do(context.Background(), retry.Policy{MaxAttempts: 3, Clock: clock}, failing(&calls,
+		errgo.Annotate(first, "calling"),
+		errgo.Annotate(second, "calling"),
+		errgo.Annotatef(third, "calling %s", "billing"),
+	))
+	c.Assert(calls, gc.Equals, 3)
+	c.Assert(errgo.Cause(err), gc.Equals, third)
+	c.Assert(errgo.IsGatewayTimeout(err), jc.IsTrue)
+	c.Assert(err, gc.ErrorMatches, "attempt 3 failed: third")
+	stack := errgo.ErrorStack(err)
+	for _, message := range []string{"first", "second", "third", "attempt 1 failed", "attempt 2 failed"} {
+		c.Check(stack, jc.Contains, message)
+	}
+}
+
+func (*retrySuite) TestNotRetryable(c *gc.C) {
+	clock := &fakeClock{}
+	calls := 0
+	notFound := errgo.NotFoundf("user")
+	err := retry.Do(context.Background(), retry.Policy{MaxAttempts: 3, Clock: clock}, failing(&calls,
+		errgo.ServiceUnavailablef("down"),
+		notFound,
+	))
+	c.Assert(calls, gc.Equals, 2)
+	c.Assert(clock.delays, gc.HasLen, 1)
+	c.Assert(err, gc.ErrorMatches, "attempt 2 failed: user")
+	c.Assert(errgo.Cause(err), gc.Equals, notFound)
+}
+
+func (*retrySuite) TestRetryablePredicate(c *gc.C) {
+	clock := &fakeClock{}
+	calls := 0
+	policy := retry.Policy{
+		MaxAttempts: 3,
+		Clock:       clock,
+		Retryable: func(err error) bool {
+			return err.Error() == "again"
+		},
+	}
+	err := retry.Do(context.Background(), policy, failing(&calls, fmt.Errorf("again"), fmt.Errorf("stop")))
+	c.Assert(calls, gc.Equals, 2)
+	c.Assert(err, gc.ErrorMatches, "attempt 2 failed: stop")
+}
+
+func (*retrySuite) TestJitter(c *gc.C) {
+	clock := &fakeClock{}
+	policy := retry.Policy{
+		MaxAttempts:  3,
+		InitialDelay: time.Second,
+		Jitter:       0.5,
+		Clock:        clock,
+		Rand: func() float64 {
+			return 0.5
+		},
+	}
+	calls := 0
+	err := retry.Do(context.Background(), policy, failing(&calls,
+		errgo.Retryable(errgo.New("first")),
+		errgo.Retryable(errgo.New("second")),
+	))
+	c.Assert(err, gc.IsNil)
+	c.Assert(clock.delays, jc.DeepEquals, []time.Duration{750 * time.Millisecond, 1500 * time.Millisecond})
+}
+
+func (*retrySuite) TestRetryAfter(c *gc.C) {
+	clock := &fakeClock{}
+	policy := retry.Policy{
+		MaxAttempts:  3,
+		InitialDelay: time.Second,
+		MaxDelay:     2 * time.Second,
+		Clock:        clock,
+	}
+	calls := 0
+	err := retry.Do(context.Background(), policy, failing(&calls,
+		errgo.WithRetryAfter(errgo.TooManyRequestsf("slow down"), 30*time.Second),
+		errgo.WithRetryAfter(errgo.TooManyRequestsf("slow down"), time.Millisecond),
+	))
+	c.Assert(err, gc.IsNil)
+	c.Assert(clock.delays, jc.DeepEquals, []time.Duration{30 * time.Second, 2 * time.Second})
+}
+
+// busy returns an error created at a single place, so that the errors it
+// returns are equal.
+func busy() error {
+	return errgo.ServiceUnavailablef("busy")
+}
+
+func (*retrySuite) TestPermanentOnLaterAttempt(c *gc.C) {
+	clock := &fakeClock{}
+	calls := 0
+	err := retry.Do(context.Background(), retry.Policy{MaxAttempts: 3, Clock: clock}, failing(&calls,
+		busy(),
+		errgo.Permanent(busy()),
+	))
+	c.Assert(calls, gc.Equals, 2)
+	c.Assert(err, gc.ErrorMatches, "attempt 2 failed: busy")
+	c.Assert(errgo.IsServiceUnavailable(err), jc.IsTrue)
+	c.Assert(errgo.IsRetryable(err), jc.IsFalse)
+	c.Assert(errgo.ErrorStack(err), jc.Contains, "attempt 1 failed")
+}
+
+func (*retrySuite) TestRetryAfterOfLastAttempt(c *gc.C) {
+	clock := &fakeClock{}
+	calls := 0
+	err := retry.Do(context.Background(), retry.Policy{MaxAttempts: 2, Clock: clock}, failing(&calls,
+		errgo.WithRetryAfter(busy(), time.Minute),
+		errgo.WithRetryAfter(busy(), 2*time.Minute),
+	))
+	c.Assert(calls, gc.Equals, 2)
+	c.Assert(err, gc.ErrorMatches, "attempt 2 failed: busy")
+	c.Assert(errgo.IsRetryable(err), jc.IsTrue)
+	c.Assert(errgo.RetryAfter(err), gc.Equals, 2*time.Minute)
+}
+
+func (*retrySuite) TestContextDone(c *gc.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	fn := func(context.Context) error {
+		calls++
+		cancel()
+		return errgo.ServiceUnavailablef("down")
+	}
+	err := retry.Do(ctx, retry.Policy{Clock: stoppedClock{}}, fn)
+	c.Assert(calls, gc.Equals, 1)
+	c.Assert(errgo.Cause(err), gc.Equals, context.Canceled)
+	c.Assert(errors.Is(err, context.Canceled), jc.IsTrue)
+	c.Assert(errgo.ErrorStack(err), jc.Contains, "attempt 1 failed")
+
+	calls = 0
+	err = retry.Do(ctx, retry.Policy{Clock: stoppedClock{}}, fn)
+	c.Assert(calls, gc.Equals, 0)
+	c.Assert(errgo.Cause(err), gc.Equals, context.Canceled)
+}
+
+func (*retrySuite) TestContextDoneBeforeRetry(c *gc.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := &fakeClock{}
+	calls := 0
+	fn := func(context.Context) error {
+		calls++
+		cancel()
+		return errgo.ServiceUnavailablef("down")
+	}
+	// The clock fires at once, but the retry is not attempted.
+	err := retry.Do(ctx, retry.Policy{MaxAttempts: 3, Clock: clock}, fn)
+	c.Assert(calls, gc.Equals, 1)
+	c.Assert(clock.delays, gc.HasLen, 0)
+	c.Assert(errgo.Cause(err), gc.Equals, context.Canceled)
+}
+
+func (*retrySuite) TestSystemClock(c *gc.C) {
+	calls := 0
+	policy := retry.Policy{
+		MaxAttempts:  2,
+		InitialDelay: time.Millisecond,
+	}
+	err := retry.Do(context.Background(), policy, failing(&calls, errgo.Retryable(errgo.New("first"))))
+	c.Assert(err, gc.IsNil)
+	c.Assert(calls, gc.Equals, 2)
+}
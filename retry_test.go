@@ -0,0 +1,176 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/hifx/errgo"
+)
+
+type retrySuite struct{}
+
+var _ = gc.Suite(&retrySuite{})
+
+// netError implements net.Error.
+type netError struct {
+	timeout, temporary bool
+}
+
+func (e *netError) Error() string   { return "network error" }
+func (e *netError) Timeout() bool   { return e.timeout }
+func (e *netError) Temporary() bool { return e.temporary }
+
+func (*retrySuite) TestIsRetryable(c *gc.C) {
+	for i, test := range []struct {
+		message   string
+		err       error
+		retryable bool
+	}{{
+		message: "nil",
+		err:     nil,
+	}, {
+		message: "plain error",
+		err:     fmt.Errorf("raw"),
+	}, {
+		message: "errgo error",
+		err:     errgo.New("first"),
+	}, {
+		message:   "retryable",
+		err:       errgo.Retryable(errgo.New("first")),
+		retryable: true,
+	}, {
+		message:   "annotated retryable",
+		err:       errgo.Annotate(errgo.Retryable(errgo.New("first")), "calling"),
+		retryable: true,
+	}, {
+		message:   "retry after",
+		err:       errgo.WithRetryAfter(fmt.Errorf("raw"), time.Second),
+		retryable: true,
+	}, {
+		message: "permanent",
+		err:     errgo.Permanent(errgo.Retryable(errgo.New("first"))),
+	}, {
+		message:   "most recent classification wins",
+		err:       errgo.Retryable(errgo.Permanent(errgo.New("first"))),
+		retryable: true,
+	}, {
+		message:   "net timeout",
+		err:       errgo.Annotate(&netError{timeout: true}, "dialing"),
+		retryable: true,
+	}, {
+		message:   "net temporary",
+		err:       fmt.Errorf("dialing: %w", &netError{temporary: true}),
+		retryable: true,
+	}, {
+		message: "other net error",
+		err:     errgo.Trace(&netError{}),
+	}, {
+		message: "deadline exceeded",
+		err:     errgo.Annotate(context.DeadlineExceeded, "calling"),
+	}, {
+		message: "wrapped deadline exceeded",
+		err:     fmt.Errorf("calling: %w", context.DeadlineExceeded),
+	}, {
+		message: "canceled",
+		err:     errgo.Trace(context.Canceled),
+	}, {
+		message:   "retryable deadline exceeded",
+		err:       errgo.Retryable(context.DeadlineExceeded),
+		retryable: true,
+	}, {
+		message: "permanent net timeout",
+		err:     errgo.Permanent(&netError{timeout: true}),
+	}, {
+		message:   "service unavailable",
+		err:       errgo.Annotate(errgo.ServiceUnavailablef("down"), "calling"),
+		retryable: true,
+	}, {
+		message:   "too many requests",
+		err:       errgo.TooManyRequestsf("slow down"),
+		retryable: true,
+	}, {
+		message:   "gateway timeout",
+		err:       errgo.GatewayTimeoutf("upstream"),
+		retryable: true,
+	}, {
+		message: "not found",
+		err:     errgo.NotFoundf("user"),
+	}, {
+		message: "permanent service unavailable",
+		err:     errgo.Permanent(errgo.ServiceUnavailablef("maintenance")),
+	}, {
+		message:   "retryable passed to Wrap",
+		err:       errgo.Wrap(errgo.Permanent(errgo.New("first")), errgo.Retryable(errgo.New("second"))),
+		retryable: true,
+	}} {
+		c.Logf("%v: %s", i, test.message)
+		c.Check(errgo.IsRetryable(test.err), gc.Equals, test.retryable)
+	}
+}
+
+func (*retrySuite) TestConstructors(c *gc.C) {
+	first := errgo.New("first")
+	err := errgo.Retryable(first) //err retryable
+	c.Assert(err.Error(), gc.Equals, "first")
+	c.Assert(errgo.Cause(err), gc.Equals, first)
+	file, _, line := err.(*errgo.Err).Location()
+	c.Assert(fmt.Sprintf("%s:%d", file, line), gc.Equals, fmt.Sprintf("%s:%d", location("retryable").file, location("retryable").line))
+
+	c.Assert(errgo.Retryable(nil), gc.IsNil)
+	c.Assert(errgo.Permanent(nil), gc.IsNil)
+	c.Assert(errgo.WithRetryAfter(nil, time.Second), gc.IsNil)
+}
+
+func (*retrySuite) TestSetRetryable(c *gc.C) {
+	err := errgo.NewErr(http.StatusNotFound, "not found")
+	retryable, ok := err.Retryable()
+	c.Assert(retryable, jc.IsFalse)
+	c.Assert(ok, jc.IsFalse)
+
+	err.SetRetryable(true)
+	retryable, ok = err.Retryable()
+	c.Assert(retryable, jc.IsTrue)
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(errgo.IsRetryable(&err), jc.IsTrue)
+
+	err.SetRetryable(false)
+	c.Assert(errgo.IsRetryable(&err), jc.IsFalse)
+
+	err.SetRetryAfter(time.Minute)
+	c.Assert(errgo.IsRetryable(&err), jc.IsTrue)
+	c.Assert(err.RetryAfter(), gc.Equals, time.Minute)
+}
+
+func (*retrySuite) TestRetryAfter(c *gc.C) {
+	c.Assert(errgo.RetryAfter(nil), gc.Equals, time.Duration(0))
+	c.Assert(errgo.RetryAfter(errgo.TooManyRequestsf("slow down")), gc.Equals, time.Duration(0))
+
+	err := errgo.WithRetryAfter(errgo.TooManyRequestsf("slow down"), 30*time.Second)
+	err = errgo.Annotate(err, "calling")
+	c.Assert(errgo.RetryAfter(err), gc.Equals, 30*time.Second)
+
+	err = errgo.WithRetryAfter(err, time.Minute)
+	c.Assert(errgo.RetryAfter(err), gc.Equals, time.Minute)
+}
+
+func (*retrySuite) TestJSON(c *gc.C) {
+	err := errgo.Permanent(errgo.WithRetryAfter(errgo.New("first"), time.Minute))
+	data, jsonErr := json.Marshal(err)
+	c.Assert(jsonErr, gc.IsNil)
+	c.Assert(string(data), jc.Contains, `"retryable":false`)
+	c.Assert(string(data), jc.Contains, `"retryable":true,"retryAfter":"1m0s"`)
+
+	var obtained errgo.Err
+	c.Assert(json.Unmarshal(data, &obtained), gc.IsNil)
+	c.Assert(errgo.IsRetryable(&obtained), jc.IsFalse)
+	c.Assert(errgo.RetryAfter(&obtained), gc.Equals, time.Minute)
+}
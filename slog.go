@@ -0,0 +1,148 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// LogValue implements slog.LogValuer, so that an *Err is logged as a group
+// rather than a flat string. See LogValue for the members of the group, and
+// NewErr for types embedding Err.
+func (e *Err) LogValue() slog.Value {
+	return LogValue(e)
+}
+
+// LogValue implements slog.LogValuer.
+func (m *MultiError) LogValue() slog.Value {
+	return LogValue(m)
+}
+
+var (
+	_ slog.LogValuer = (*Err)(nil)
+	_ slog.LogValuer = (*MultiError)(nil)
+)
+
+// LogValue returns the slog group value used to log err. It holds the
+// error message, the HTTP response code and application error code as
+// returned by Code and ErrorCodeOf, the message of the Cause of err if it
+// differs, the location of err, the lines of the error stack, the merged
+// fields of the error stack and its ContextValues. Members without a
+// value are omitted. Sensitive data is redacted from the messages, stack
+// and fields, as by SafeError, SafeErrorStack and SafeFields.
+func LogValue(err error) slog.Value {
+	if err == nil {
+		return slog.StringValue("<nil>")
+	}
+	attrs := []slog.Attr{
+		slog.String("message", SafeError(err)),
+	}
+	if code := Code(err); code != 0 {
+		attrs = append(attrs, slog.Int("code", code))
+	}
+	if c := ErrorCodeOf(err); c != nil {
+		attrs = append(attrs, slog.String("errorCode", c.Code))
+	}
+	if cause := Cause(err); !sameError(cause, err) {
+		attrs = append(attrs, slog.String("cause", SafeError(cause)))
+	}
+	located := false
+	if err, ok := err.(locationer); ok {
+		file, function, line := err.Location()
+		if file != "" {
+			located = true
+			attrs = append(attrs,
+				slog.String("location", fmt.Sprintf("%s:%d", file, line)),
+				slog.String("function", function),
+			)
+		}
+	}
+	// A single unlocated entry would only repeat the message.
+	if stack := stackLines(err, trimPackage, true); len(stack) > 1 || located {
+		attrs = append(attrs, slog.Any("stack", stack))
+	}
+	if fields := SafeFields(err); len(fields) > 0 {
+		fieldAttrs := make([]interface{}, len(fields))
+		for i, field := range fields {
+			fieldAttrs[i] = slog.Any(field.Key, field.Value)
+		}
+		attrs = append(attrs, slog.Group("fields", fieldAttrs...))
+	}
+	if values := ContextValues(err); len(values) > 0 {
+		var contextAttrs []interface{}
+		for _, name := range sortedNames(values) {
+			contextAttrs = append(contextAttrs, slog.String(name, values[name]))
+		}
+		attrs = append(attrs, slog.Group("context", contextAttrs...))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// SlogHandler is a slog.Handler which expands every attribute holding an
+// error into the group returned by LogValue before passing it on to the
+// handler it wraps, so that errors from outside this package are logged
+// with the same structure as an *Err, and types embedding Err with the
+// message of their own Error method.
+type SlogHandler struct {
+	handler slog.Handler
+}
+
+// NewSlogHandler returns a SlogHandler wrapping h.
+//
+// For example:
+//     logger := slog.New(errgo.NewSlogHandler(slog.NewJSONHandler(os.Stderr, nil)))
+//     logger.Error("request failed", "err", err)
+func NewSlogHandler(h slog.Handler) *SlogHandler {
+	return &SlogHandler{handler: h}
+}
+
+// Enabled implements slog.Handler.
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler.
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	expanded := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		expanded.AddAttrs(expandAttr(a))
+		return true
+	})
+	return h.handler.Handle(ctx, expanded)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	expanded := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		expanded[i] = expandAttr(a)
+	}
+	return &SlogHandler{handler: h.handler.WithAttrs(expanded)}
+}
+
+// WithGroup implements slog.Handler.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	return &SlogHandler{handler: h.handler.WithGroup(name)}
+}
+
+// expandAttr returns a with any error value, including those within
+// groups, replaced by its LogValue.
+func expandAttr(a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindAny, slog.KindLogValuer:
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = LogValue(err)
+		}
+	case slog.KindGroup:
+		group := a.Value.Group()
+		expanded := make([]slog.Attr, len(group))
+		for i, a := range group {
+			expanded[i] = expandAttr(a)
+		}
+		a.Value = slog.GroupValue(expanded...)
+	}
+	return a
+}
@@ -0,0 +1,201 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/hifx/errgo"
+)
+
+type slogSuite struct{}
+
+var _ = gc.Suite(&slogSuite{})
+
+// logJSON logs err with the given handler wrapper and returns the decoded
+// "err" attribute.
+func logJSON(c *gc.C, wrap func(slog.Handler) slog.Handler, args ...interface{}) map[string]interface{} {
+	var buf bytes.Buffer
+	h := slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	slog.New(wrap(h)).Error("failed", args...)
+	var record map[string]interface{}
+	c.Assert(json.Unmarshal(buf.Bytes(), &record), gc.IsNil)
+	return record
+}
+
+func noWrap(h slog.Handler) slog.Handler {
+	return h
+}
+
+func wrapHandler(h slog.Handler) slog.Handler {
+	return errgo.NewSlogHandler(h)
+}
+
+func (*slogSuite) TestLogValue(c *gc.C) {
+	first := errgo.New("first")                                      //err slog-0 (*slogSuite).TestLogValue
+	err := errgo.AnnotateWith(first, "loading", errgo.F("user", 42)) //err slog-1 (*slogSuite).TestLogValue
+	err = errgo.Wrap(err, errgo.NotFoundf("user"))                   //err slog-2 (*slogSuite).TestLogValue
+	err = errgo.Annotate(err, "handling request")                    //err slog-3 (*slogSuite).TestLogValue
+	record := logJSON(c, noWrap, "err", err)
+	c.Assert(record["err"], jc.DeepEquals, map[string]interface{}{
+		"message":  "handling request: user",
+		"code":     float64(404),
+		"cause":    "user",
+		"location": fmt.Sprintf("%s:%d", location("slog-3").file, location("slog-3").line),
+		"function": "github.com/hifx/errgo_test.(*slogSuite).TestLogValue",
+		"stack": []interface{}{
+			replaceLocations("$slog-0$: first"),
+			replaceLocations("$slog-1$: loading user=42"),
+			replaceLocations("$slog-2$: user"),
+			replaceLocations("$slog-3$: handling request"),
+		},
+		"fields": map[string]interface{}{"user": float64(42)},
+	})
+}
+
+var errSlogQuotaExceeded = errgo.MustRegisterCode(errgo.ErrorCode{
+	Code:    "SLOG_QUOTA_EXCEEDED",
+	Status:  429,
+	Message: "quota exceeded",
+})
+
+func (*slogSuite) TestLogValueErrorCode(c *gc.C) {
+	err := errgo.Codef(errSlogQuotaExceeded, "")
+	value := errgo.LogValue(err).Resolve()
+	c.Assert(value.Kind(), gc.Equals, slog.KindGroup)
+	attrs := make(map[string]slog.Value)
+	for _, a := range value.Group() {
+		attrs[a.Key] = a.Value
+	}
+	c.Assert(attrs["code"].Int64(), gc.Equals, int64(429))
+	c.Assert(attrs["errorCode"].String(), gc.Equals, "SLOG_QUOTA_EXCEEDED")
+	_, ok := attrs["cause"]
+	c.Assert(ok, jc.IsFalse)
+}
+
+func (*slogSuite) TestLogValueNil(c *gc.C) {
+	c.Assert(errgo.LogValue(nil).String(), gc.Equals, "<nil>")
+}
+
+func (*slogSuite) TestLogValueMultiError(c *gc.C) {
+	err := errgo.Join(errgo.NotFoundf("first"), errgo.BadRequestf("second"))
+	record := logJSON(c, noWrap, "err", err)
+	logged := record["err"].(map[string]interface{})
+	c.Assert(logged["message"], gc.Equals, "first; second")
+	c.Assert(logged["code"], gc.Equals, float64(404))
+}
+
+// errorList is an error type which cannot be compared with ==.
+type errorList []string
+
+func (e errorList) Error() string {
+	return strings.Join(e, "; ")
+}
+
+func (*slogSuite) TestLogValueUncomparable(c *gc.C) {
+	err := errorList{"first", "second"}
+	record := logJSON(c, wrapHandler, "err", err)
+	c.Assert(record["err"], jc.DeepEquals, map[string]interface{}{
+		"message": "first; second",
+	})
+
+	record = logJSON(c, noWrap, "err", errgo.Annotate(err, "loading"))
+	logged := record["err"].(map[string]interface{})
+	c.Assert(logged["message"], gc.Equals, "loading: first; second")
+	c.Assert(logged["cause"], gc.Equals, "first; second")
+}
+
+// opError embeds errgo.Err, defining its own Error and SafeError
+// methods.
+type opError struct {
+	errgo.Err
+	op string
+}
+
+func (e *opError) Error() string {
+	return e.op + ": " + e.Err.Error()
+}
+
+func (e *opError) SafeError() string {
+	return e.op + ": " + e.Err.SafeError()
+}
+
+func (*slogSuite) TestLogValueEmbedded(c *gc.C) {
+	err := &opError{errgo.NewErr(404, "no such user"), "lookup"}
+	value := errgo.LogValue(err)
+	c.Assert(value.Group()[0], jc.DeepEquals, slog.String("message", "lookup: no such user"))
+
+	record := logJSON(c, wrapHandler, "err", err)
+	logged := record["err"].(map[string]interface{})
+	c.Assert(logged["message"], gc.Equals, "lookup: no such user")
+	c.Assert(logged["code"], gc.Equals, float64(404))
+}
+
+func (*slogSuite) TestLogValueRedacted(c *gc.C) {
+	defer errgo.ResetRedactionRules()()
+	errgo.RedactKeys("password")
+	err := errgo.Errorf("no user %s", errgo.Sensitive("jo@example.com"))
+	err = errgo.AnnotateWith(err, "logging in", errgo.F("password", "hunter2"), errgo.F("user", 42))
+	err = errgo.Wrap(err, errgo.Unauthorizedf("token %s", errgo.Sensitive("s3cr3t")))
+	for _, wrap := range []func(slog.Handler) slog.Handler{noWrap, wrapHandler} {
+		var buf bytes.Buffer
+		slog.New(wrap(slog.NewJSONHandler(&buf, nil))).Error("failed", "err", err)
+		output := buf.String()
+		c.Check(output, gc.Not(jc.Contains), "jo@example.com")
+		c.Check(output, gc.Not(jc.Contains), "hunter2")
+		c.Check(output, gc.Not(jc.Contains), "s3cr3t")
+		c.Check(output, jc.Contains, `"user":42`)
+		c.Check(output, jc.Contains, `"password":"[REDACTED]"`)
+	}
+}
+
+func (*slogSuite) TestHandlerWithoutWrapper(c *gc.C) {
+	record := logJSON(c, noWrap, "err", fmt.Errorf("raw"))
+	c.Assert(record["err"], gc.Equals, "raw")
+}
+
+func (*slogSuite) TestHandlerExpandsErrors(c *gc.C) {
+	record := logJSON(c, wrapHandler, "err", fmt.Errorf("raw"))
+	c.Assert(record["err"], jc.DeepEquals, map[string]interface{}{
+		"message": "raw",
+	})
+
+	wrapped := fmt.Errorf("outer: %w", errgo.NotFoundf("user"))
+	record = logJSON(c, wrapHandler, slog.Group("request", "err", wrapped))
+	c.Assert(record["request"], jc.DeepEquals, map[string]interface{}{
+		"err": map[string]interface{}{
+			"message": "outer: user",
+			"code":    float64(404),
+		},
+	})
+}
+
+func (*slogSuite) TestHandlerWithAttrs(c *gc.C) {
+	withAttrs := func(h slog.Handler) slog.Handler {
+		return errgo.NewSlogHandler(h).WithAttrs([]slog.Attr{
+			slog.Any("err", fmt.Errorf("raw")),
+		}).WithGroup("g")
+	}
+	record := logJSON(c, withAttrs, "n", 1)
+	c.Assert(record["err"], jc.DeepEquals, map[string]interface{}{
+		"message": "raw",
+	})
+	c.Assert(record["g"], jc.DeepEquals, map[string]interface{}{
+		"n": float64(1),
+	})
+}
@@ -0,0 +1,92 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package errgo
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+)
+
+// maxStackDepth is the maximum number of program counters recorded when
+// stack capture is enabled.
+const maxStackDepth = 32
+
+// stackCapture records whether new errors capture the full call stack.
+var stackCapture atomic.Bool
+
+// SetStackCapture sets whether New, Errorf and the constructors in
+// errortypes.go record the full call stack of the goroutine creating the
+// error, in addition to the single location recorded by SetLocation. It is
+// off by default, as capturing the stack makes creating errors slower.
+func SetStackCapture(enabled bool) {
+	stackCapture.Store(enabled)
+}
+
+// StackCapture reports whether new errors capture the full call stack.
+func StackCapture() bool {
+	return stackCapture.Load()
+}
+
+// Frame holds a single entry of a call stack captured when the error was
+// created.
+type Frame struct {
+	// File holds the source file, trimmed as for Location.
+	File string
+
+	// Line holds the line number within File.
+	Line int
+
+	// Function holds the fully qualified function name.
+	Function string
+}
+
+// String returns the frame in the same form as the locations shown by
+// ErrorStack.
+func (f Frame) String() string {
+	return fmt.Sprintf("%s:%d %s", f.File, f.Line, trimPackage(f.Function))
+}
+
+type stackFramer interface {
+	StackFrames() []Frame
+}
+
+var _ stackFramer = (*Err)(nil)
+
+// captureStack records the program counters of the call stack at callDepth
+// stack frames above the call, if stack capture is enabled.
+func (e *Err) captureStack(callDepth int) {
+	if !StackCapture() {
+		return
+	}
+	var pcs [maxStackDepth]uintptr
+	n := runtime.Callers(callDepth+2, pcs[:])
+	e.pcs = append([]uintptr(nil), pcs[:n]...)
+}
+
+// StackFrames returns the call stack captured when the error was created,
+// innermost call first, or nil if stack capture was not enabled at the
+// time. The program counters are only symbolized when StackFrames is
+// called.
+func (e *Err) StackFrames() []Frame {
+	if len(e.pcs) == 0 {
+		return nil
+	}
+	var result []Frame
+	frames := runtime.CallersFrames(e.pcs)
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "runtime.goexit" {
+			result = append(result, Frame{
+				File:     trimSourcePath(frame.File, frame.Function),
+				Line:     frame.Line,
+				Function: frame.Function,
+			})
+		}
+		if !more {
+			break
+		}
+	}
+	return result
+}